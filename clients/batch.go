@@ -0,0 +1,154 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// The result of an element of a batch request.
+type BatchResult[V any] struct {
+	Value V
+	Found bool  // false if the result is null
+	Err   error // the error of the last attempt
+}
+
+// The first retry of a batch element waits this long, then it doubles.
+const batchBackoff = time.Millisecond
+
+// Call method with every element of args in one batch request, elements
+// which failed or returned null are called again in a smaller batch with
+// exponential backoff, up to attempts times in total. decode is called on
+// results which are not null.
+//
+// emit is called with the index and the result of every element as soon as
+// it is final, i.e., after the attempt it succeeded in, or after the last
+// attempt, so that slow elements don't hold back the others.
+//
+// err is returned if a batch request itself failed, e.g., the connection
+// dropped, elements not emitted yet are discarded.
+func batchCall[V any](ctx context.Context, rpcClient *rpc.Client, method string, args []interface{}, attempts int, decode func(raw json.RawMessage) (V, error), emit func(i int, result BatchResult[V])) error {
+	results := make([]BatchResult[V], len(args))
+	remaining := make([]int, len(args)) // indexes of elements to call
+	for i := range args {
+		remaining[i] = i
+	}
+
+	interval := batchBackoff
+	for attempt := 0; attempt < attempts && len(remaining) > 0; attempt++ {
+		if attempt > 0 {
+			if !sleep(ctx, interval) {
+				return ctx.Err()
+			}
+			interval *= 2
+		}
+
+		raws := make([]json.RawMessage, len(remaining))
+		elems := make([]rpc.BatchElem, len(remaining))
+		for j, i := range remaining {
+			elems[j] = rpc.BatchElem{Method: method, Args: []interface{}{args[i]}, Result: &raws[j]}
+		}
+		if err := rpcClient.BatchCallContext(ctx, elems); err != nil {
+			if ctx.Err() == nil {
+				metrics.RPCErrors.WithLabelValues(method).Inc()
+			}
+			return err
+		}
+
+		failed := remaining[:0]
+		for j, i := range remaining {
+			result := &results[i]
+			switch {
+			case elems[j].Error != nil:
+				metrics.RPCErrors.WithLabelValues(method).Inc()
+				result.Err = elems[j].Error
+				failed = append(failed, i)
+			case len(raws[j]) == 0 || string(raws[j]) == "null":
+				result.Err = nil
+				failed = append(failed, i)
+			default:
+				// decode errors would be the same next time
+				result.Value, result.Err = decode(raws[j])
+				result.Found = result.Err == nil
+				emit(i, *result)
+			}
+		}
+		remaining = failed
+	}
+	for _, i := range remaining {
+		emit(i, results[i])
+	}
+	return nil
+}
+
+// Call batchCall() and collect the results in the order of args.
+func batchCollect[V any](ctx context.Context, rpcClient *rpc.Client, method string, args []interface{}, attempts int, decode func(raw json.RawMessage) (V, error)) ([]BatchResult[V], error) {
+	results := make([]BatchResult[V], len(args))
+	err := batchCall(ctx, rpcClient, method, args, attempts, decode, func(i int, result BatchResult[V]) {
+		results[i] = result
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// A transaction fetched by its hash, BlockNumber is nil if it is pending.
+type FetchedTx struct {
+	*types.Transaction
+	BlockNumber *big.Int
+}
+
+func (tx *FetchedTx) IsPending() bool {
+	return tx.BlockNumber == nil
+}
+
+func decodeFetchedTx(raw json.RawMessage) (*FetchedTx, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	var extra struct {
+		BlockNumber *hexutil.Big `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(raw, &extra); err != nil {
+		return nil, err
+	}
+	return &FetchedTx{Transaction: tx, BlockNumber: (*big.Int)(extra.BlockNumber)}, nil
+}
+
+// Fetch transactions by hashes with eth_getTransactionByHash in batches, see
+// batchCall(). Transactions not found yet are fetched again.
+func FetchTransactions(ctx context.Context, rpcClient *rpc.Client, hashes []common.Hash, attempts int) ([]BatchResult[*FetchedTx], error) {
+	return batchCollect(ctx, rpcClient, "eth_getTransactionByHash", hashArgs(hashes), attempts, decodeFetchedTx)
+}
+
+func decodeReceipt(raw json.RawMessage) (*types.Receipt, error) {
+	receipt := new(types.Receipt)
+	if err := receipt.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// Fetch receipts by transaction hashes with eth_getTransactionReceipt in
+// batches, see batchCall(). Receipts of pending transactions are fetched
+// again, until they are mined or attempts run out.
+func FetchReceipts(ctx context.Context, rpcClient *rpc.Client, hashes []common.Hash, attempts int) ([]BatchResult[*types.Receipt], error) {
+	return batchCollect(ctx, rpcClient, "eth_getTransactionReceipt", hashArgs(hashes), attempts, decodeReceipt)
+}
+
+func hashArgs(hashes []common.Hash) []interface{} {
+	args := make([]interface{}, len(hashes))
+	for i, hash := range hashes {
+		args[i] = hash
+	}
+	return args
+}
@@ -0,0 +1,97 @@
+package clients
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/testutil"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// A fake node with a mined and a pending transaction.
+func newBatchTestNode(t *testing.T) (*testutil.Node, *rpc.Client, *types.Transaction, *types.Transaction) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	mined := testutil.NewTx(key, 0, testPair1, nil)
+	pending := testutil.NewTx(key, 1, testPair1, nil)
+	node := testutil.NewNode(t, testutil.Timeline{Start: 100, Blocks: []testutil.Block{
+		{Txs: []*types.Transaction{mined}},
+		{Txs: []*types.Transaction{pending}},
+	}})
+	rpcClient, err := rpc.Dial(node.URL())
+	assert.NoError(t, err)
+	t.Cleanup(rpcClient.Close)
+	return node, rpcClient, mined, pending
+}
+
+func TestFetchTransactions(t *testing.T) {
+	node, rpcClient, mined, pending := newBatchTestNode(t)
+	ctx := context.Background()
+	unknown := common.HexToHash("0x01")
+	hashes := []common.Hash{mined.Hash(), pending.Hash(), unknown}
+
+	// a failed element is retried alone
+	node.Fail("eth_getTransactionByHash", 1)
+	results, err := FetchTransactions(ctx, rpcClient, hashes, 2)
+	assert.NoError(t, err)
+	assert.True(t, results[0].Found)
+	assert.Equal(t, mined.Hash(), results[0].Value.Hash())
+	assert.False(t, results[0].Value.IsPending())
+	assert.Equal(t, uint64(100), results[0].Value.BlockNumber.Uint64())
+	assert.True(t, results[1].Found)
+	assert.Equal(t, pending.Hash(), results[1].Value.Hash())
+	assert.True(t, results[1].Value.IsPending())
+	assert.Equal(t, BatchResult[*FetchedTx]{}, results[2])
+
+	// out of attempts
+	node.Fail("eth_getTransactionByHash", 1)
+	results, err = FetchTransactions(ctx, rpcClient, hashes, 1)
+	assert.NoError(t, err)
+	assert.False(t, results[0].Found)
+	assert.ErrorContains(t, results[0].Err, testutil.ErrInjected.Error())
+	assert.True(t, results[1].Found)
+
+	// the batch request itself failed
+	node.Drop()
+	_, err = FetchTransactions(ctx, rpcClient, hashes, 3)
+	assert.Error(t, err)
+}
+
+func TestBatchCallEmit(t *testing.T) {
+	node, rpcClient, mined, pending := newBatchTestNode(t)
+	hashes := []common.Hash{mined.Hash(), pending.Hash(), common.HexToHash("0x01")}
+
+	// found elements are emitted before the failed ones are retried
+	node.Fail("eth_getTransactionByHash", 1)
+	emitted := make([]int, 0)
+	err := batchCall(context.Background(), rpcClient, "eth_getTransactionByHash", hashArgs(hashes), 2, decodeFetchedTx, func(i int, result BatchResult[*FetchedTx]) {
+		assert.Equal(t, i != 2, result.Found, i)
+		emitted = append(emitted, i)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 0, 2}, emitted)
+}
+
+func TestFetchReceipts(t *testing.T) {
+	node, rpcClient, mined, pending := newBatchTestNode(t)
+	ctx := context.Background()
+
+	results, err := FetchReceipts(ctx, rpcClient, []common.Hash{mined.Hash(), pending.Hash()}, 2)
+	assert.NoError(t, err)
+	assert.True(t, results[0].Found)
+	assert.Equal(t, mined.Hash(), results[0].Value.TxHash)
+	assert.Equal(t, types.ReceiptStatusSuccessful, results[0].Value.Status)
+	assert.Equal(t, node.Header(100).Hash(), results[0].Value.BlockHash)
+	assert.False(t, results[1].Found) // pending
+
+	// found once mined
+	node.Mine()
+	results, err = FetchReceipts(ctx, rpcClient, []common.Hash{pending.Hash()}, 1)
+	assert.NoError(t, err)
+	assert.True(t, results[0].Found)
+	assert.Equal(t, uint64(101), results[0].Value.BlockNumber.Uint64())
+}
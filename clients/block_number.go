@@ -2,23 +2,37 @@ package clients
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"math/big"
 	"sync"
 
 	"github.com/crypto-crawler/bloxroute-go/client"
 	bloXrouteTypes "github.com/crypto-crawler/bloxroute-go/types"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
-// Get current block number
+// Get current block number, updated in the background until ctx is done or
+// Close() is called.
 type BlockNumber struct {
+	*utils.Handle
 	blockNumber *big.Int
 	rw          *sync.RWMutex
 }
 
-func NewBlockNumberOnBloXroute(bloXrouteClient *client.BloXrouteClient, stopCh <-chan struct{}) (*BlockNumber, error) {
+func parseBlockNumber(resp *bloXrouteTypes.EthOnBlockResponse) (*big.Int, error) {
+	number, ok := big.NewInt(0).SetString(resp.Response, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid block number: %v", resp)
+	}
+	return number, nil
+}
+
+func NewBlockNumberOnBloXroute(ctx context.Context, bloXrouteClient *client.BloXrouteClient) (*BlockNumber, error) {
 	outCh := make(chan *bloXrouteTypes.EthOnBlockResponse)
 	callParams := make([]map[string]string, 0)
 	callParams = append(callParams, map[string]string{"name": "block_number", "method": "eth_blockNumber"})
@@ -27,93 +41,104 @@ func NewBlockNumberOnBloXroute(bloXrouteClient *client.BloXrouteClient, stopCh <
 		return nil, err
 	}
 
-	rw := sync.RWMutex{}
+	// Initialize the first block number
 	blockNumber := big.NewInt(0)
-	go func() {
+INIT:
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case resp, ok := <-outCh:
+			if !ok {
+				return nil, errors.New("the ethOnBlock stream is closed")
+			}
+			if resp.Name == "block_number" {
+				number, err := parseBlockNumber(resp)
+				if err != nil {
+					return nil, err
+				}
+				blockNumber.Set(number)
+				break INIT
+			}
+		}
+	}
+
+	rw := sync.RWMutex{}
+	handle := utils.NewHandle(ctx, func(ctx context.Context) error {
 		for {
 			select {
-			case <-stopCh:
-				close(outCh)
-				return
-			case resp := <-outCh:
+			case <-ctx.Done():
+				return nil
+			case resp, ok := <-outCh:
+				if !ok {
+					return nil
+				}
 				if resp.Name == "block_number" {
-					if number, ok := big.NewInt(0).SetString(resp.Response, 0); ok {
-						rw.Lock()
-						blockNumber.Set(number)
-						rw.Unlock()
-					} else {
-						log.Fatal(resp)
+					number, err := parseBlockNumber(resp)
+					if err != nil {
+						return err
 					}
+					rw.Lock()
+					blockNumber.Set(number)
+					rw.Unlock()
 				}
 			}
 		}
-	}()
-
-	// Initialize the first block number
-	for resp := range outCh {
-		if resp.Name == "block_number" {
-			if number, ok := big.NewInt(0).SetString(resp.Response, 0); ok {
-				blockNumber.Set(number)
-				break
-			} else {
-				log.Fatal(resp)
-			}
-		}
-	}
+	})
 
 	return &BlockNumber{
+		Handle:      handle,
 		blockNumber: blockNumber,
 		rw:          &rw,
 	}, nil
 }
 
-func NewBlockNumberOnFullnode(fullnodeUrl string, stopCh <-chan struct{}) (*BlockNumber, error) {
-	ctx := context.Background()
-	ethClient, err := ethclient.DialContext(ctx, fullnodeUrl)
-	if err != nil {
-		return nil, err
-	}
-
-	headCh := make(chan *types.Header)
-	sub, err := ethClient.SubscribeNewHead(ctx, headCh)
+// The subscription of new heads is restored automatically if the connection
+// drops, and the block number is refreshed right after that.
+func NewBlockNumberOnFullnode(ctx context.Context, fullnodeUrl string) (*BlockNumber, error) {
+	r, err := newResubscriber(ctx, fullnodeUrl, subscribeNewHead)
 	if err != nil {
 		return nil, err
 	}
 
 	blockNumber := big.NewInt(0)
 	{
-		header, err := ethClient.HeaderByNumber(ctx, nil)
+		header, err := ethclient.NewClient(r.rpcClient).HeaderByNumber(ctx, nil)
 		if err != nil {
+			r.close()
 			return nil, err
 		}
 		blockNumber = header.Number
 	}
 
 	rw := sync.RWMutex{}
-	go func() {
-	DONE:
-		for {
-			select {
-			case <-stopCh:
-				break DONE
-			case err := <-sub.Err():
-				log.Println(err)
-				break DONE
-			case head := <-headCh:
-				// log.Println("New block:", head.Number.Uint64())
-				rw.Lock()
-				blockNumber.Set(head.Number)
-				rw.Unlock()
-			}
+	set := func(number *big.Int) {
+		rw.Lock()
+		defer rw.Unlock()
+		// heads might be delivered out of order around reconnections
+		if number.Cmp(blockNumber) > 0 {
+			blockNumber.Set(number)
 		}
+	}
+	r.onOutage = func(rpcClient *rpc.Client, outage Outage) {
+		log.Printf("Resubscribed to new heads after %v, error: %v", outage.End.Sub(outage.Start), outage.Err)
+		header, err := ethclient.NewClient(rpcClient).HeaderByNumber(ctx, nil)
+		if err == nil {
+			set(header.Number)
+		}
+	}
 
-		sub.Unsubscribe()
-		close(headCh)
-		ctx.Done()
-		ethClient.Close()
-	}()
+	handle := utils.NewHandle(ctx, func(ctx context.Context) error {
+		r.run(ctx, func(_ *rpc.Client, head *types.Header) bool {
+			// log.Println("New block:", head.Number.Uint64())
+			set(head.Number)
+			return true
+		})
+		return nil
+	})
 
 	return &BlockNumber{
+		Handle:      handle,
 		blockNumber: blockNumber,
 		rw:          &rw,
 	}, nil
@@ -1,6 +1,7 @@
 package clients
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
@@ -20,8 +21,9 @@ func TestBlockNumberOnBloXroute(t *testing.T) {
 	client, err := client.NewBloXrouteClientToCloud("BSC-Mainnet", certFile, keyFile, stopCh)
 	assert.NoError(t, err)
 
-	blockNumber, err := NewBlockNumberOnBloXroute(client, stopCh)
+	blockNumber, err := NewBlockNumberOnBloXroute(context.Background(), client)
 	assert.NoError(t, err)
+	defer blockNumber.Close()
 
 	number1 := blockNumber.Get()
 	time.Sleep(time.Second * 4)
@@ -33,20 +35,15 @@ func TestBlockNumberOnBloXroute(t *testing.T) {
 }
 
 func TestBlockNumberOnFullnode(t *testing.T) {
-	fullnodeUrl := os.Getenv("FULLNODE_URL")
-	if fullnodeUrl == "" {
-		assert.FailNow(t, "Please provide the fullnode URL in the FULLNODE_URL environment variable")
-	}
+	node := newTestNode(t)
 
-	stopCh := make(chan struct{})
-	blockNumber, err := NewBlockNumberOnFullnode(fullnodeUrl, stopCh)
+	blockNumber, err := NewBlockNumberOnFullnode(context.Background(), node.URL())
 	assert.NoError(t, err)
+	defer blockNumber.Close()
 
 	number1 := blockNumber.Get()
-	time.Sleep(time.Second * 4)
+	time.Sleep(100 * time.Millisecond)
 	number2 := blockNumber.Get()
 
 	assert.Greater(t, number2.Uint64(), number1.Uint64())
-
-	close(stopCh)
 }
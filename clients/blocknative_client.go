@@ -1,13 +1,19 @@
 package clients
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/crypto-crawler/fullnode-benchmarks/constant"
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
 	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/gorilla/websocket"
 )
@@ -16,66 +22,127 @@ import (
 
 // BlocknativeClient wraps gorilla websocket connections
 type BlocknativeClient struct {
-	apiKey        string
-	system        string
-	network       string
+	config   BlocknativeConfig
+	conn     *websocket.Conn
+	writeMtx sync.Mutex // serializes writes, messages are read by one goroutine at a time
+	connMtx  sync.Mutex // guards conn and closed, because reconnect() replaces conn
+	closed   bool
+
+	// guards the whitelists and subscribed, it is held while configs are
+	// put, so that updates are neither lost nor put twice across reconnections
+	configMtx     sync.Mutex
 	fromWhiteList map[common.Address]bool
 	toWhiteList   map[common.Address]bool
-	conn          *websocket.Conn
-	mtx           sync.RWMutex
+	subscribed    bool // whether configs have been put
 }
 
-// Create a blocknative websocket client.
-//
-// `system`, available values are: bitcoin, ethereum.
-//
-// `network`, available values are: main, ropsten, rinkeby, goerli, kovan,
-// xdai, bsc-main, see https://docs.blocknative.com/mempool-explorer#supported-networks
+// The websocket endpoint of Blocknative.
+const BlocknativeUrl = "wss://api.blocknative.com/v0"
+
+// Where to connect to Blocknative and what to watch.
+type BlocknativeConfig struct {
+	ApiKey string
+	Url    string // BlocknativeUrl if empty
+	// `System`, available values are: bitcoin, ethereum, ethereum if empty.
+	System string
+	// `Network`, available values are: main, ropsten, rinkeby, goerli, kovan,
+	// xdai, bsc-main, see https://docs.blocknative.com/mempool-explorer#supported-networks
+	Network string
+	// Filters of a global config, e.g., {"status": "pending"}, see
+	// BlocknativeFilter.Map()
+	Filters []map[string]interface{}
+	// Watch addLiquidity/addLiquidityETH calls to Router, which is the
+	// PancakeSwap V2 router if zero.
+	WatchRouter   bool
+	Router        common.Address
+	FromWhiteList map[common.Address]bool
+	ToWhiteList   map[common.Address]bool
+}
+
+// Create a blocknative websocket client, which watches the PancakeSwap V2
+// router on bsc-main, see BlocknativeConfig.
 func NewBlocknativeClient(apiKey string, system string, network string, fromWhiteList map[common.Address]bool, toWhiteList map[common.Address]bool) (*BlocknativeClient, error) {
-	conn, _, err := websocket.DefaultDialer.Dial("wss://api.blocknative.com/v0", nil)
-	if err != nil {
-		return nil, err
+	return DialBlocknative(BlocknativeConfig{
+		ApiKey:        apiKey,
+		System:        system,
+		Network:       network,
+		WatchRouter:   network == "bsc-main",
+		FromWhiteList: fromWhiteList,
+		ToWhiteList:   toWhiteList,
+	})
+}
+
+// Connect to Blocknative and initialize the connection.
+func DialBlocknative(config BlocknativeConfig) (*BlocknativeClient, error) {
+	if config.ApiKey == "" {
+		return nil, errors.New("the API key is required for blocknative")
+	}
+	if config.Network == "" {
+		return nil, errors.New("the network is required for blocknative")
+	}
+	if config.Url == "" {
+		config.Url = BlocknativeUrl
+	}
+	if config.System == "" {
+		config.System = "ethereum"
+	}
+	if config.Router == (common.Address{}) {
+		config.Router = common.HexToAddress(constant.PANCAKESWAP_V2_ROUTER_ADDRESS)
 	}
 
-	// this checks out connection to blocknative's api and makes sure that we connected properly
-	var out pojo.ConnectResponse
-	if err := conn.ReadJSON(&out); err != nil {
+	conn, err := dialBlocknative(config.Url)
+	if err != nil {
 		return nil, err
 	}
-	if out.Status != "ok" {
-		return nil, fmt.Errorf("failed to initialize websockets connection reason: %s", out.Reason)
-	}
 
 	client := &BlocknativeClient{
-		apiKey:        apiKey,
-		system:        system,
-		network:       network,
-		fromWhiteList: fromWhiteList,
-		toWhiteList:   toWhiteList,
+		config:        config,
+		fromWhiteList: copyWhiteList(config.FromWhiteList),
+		toWhiteList:   copyWhiteList(config.ToWhiteList),
 		conn:          conn,
 	}
 	err = client.initialize()
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
 	return client, nil
 }
 
-func (c *BlocknativeClient) reconnect() error {
-	conn, _, err := websocket.DefaultDialer.Dial("wss://api.blocknative.com/v0", nil)
+// Connect and wait for the connect response.
+func dialBlocknative(url string) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// this checks out connection to blocknative's api and makes sure that we connected properly
 	var out pojo.ConnectResponse
 	if err := conn.ReadJSON(&out); err != nil {
-		return err
+		conn.Close()
+		return nil, err
 	}
 	if out.Status != "ok" {
-		return fmt.Errorf("failed to initialize websockets connection reason: %s", out.Reason)
+		conn.Close()
+		return nil, fmt.Errorf("failed to initialize websockets connection reason: %s", out.Reason)
+	}
+	return conn, nil
+}
+
+func (c *BlocknativeClient) reconnect() error {
+	conn, err := dialBlocknative(c.config.Url)
+	if err != nil {
+		return err
+	}
+
+	c.connMtx.Lock()
+	if c.closed {
+		c.connMtx.Unlock()
+		conn.Close()
+		return errClientClosed
 	}
 	c.conn = conn
+	c.connMtx.Unlock()
 
 	err = c.initialize()
 	if err != nil {
@@ -85,9 +152,146 @@ func (c *BlocknativeClient) reconnect() error {
 	return nil
 }
 
-func (c *BlocknativeClient) ResetFromToList(fromWhiteList map[common.Address]bool, toWhiteList map[common.Address]bool) {
-	c.fromWhiteList = fromWhiteList
-	c.toWhiteList = toWhiteList
+var errClientClosed = errors.New("the blocknative client is closed")
+
+func copyWhiteList(whitelist map[common.Address]bool) map[common.Address]bool {
+	copied := make(map[common.Address]bool, len(whitelist))
+	for address, ok := range whitelist {
+		if ok {
+			copied[address] = true
+		}
+	}
+	return copied
+}
+
+// Replace the whitelists, only the differences are put or removed on the
+// server if subscribed. The maps are copied.
+func (c *BlocknativeClient) ResetFromToList(fromWhiteList map[common.Address]bool, toWhiteList map[common.Address]bool) error {
+	c.configMtx.Lock()
+	defer c.configMtx.Unlock()
+
+	added, removed := diffWhiteList(c.fromWhiteList, fromWhiteList)
+	commands := c.updateWhiteList(added, removed, false)
+	added, removed = diffWhiteList(c.toWhiteList, toWhiteList)
+	commands = append(commands, c.updateWhiteList(added, removed, true)...)
+	return c.putLive(commands)
+}
+
+// Watch more addresses, transactions from or to them are sent by the
+// subscription once the server has received the configs.
+func (c *BlocknativeClient) AddWhiteList(fromAddresses []common.Address, toAddresses []common.Address) error {
+	c.configMtx.Lock()
+	defer c.configMtx.Unlock()
+
+	commands := c.updateWhiteList(fromAddresses, nil, false)
+	commands = append(commands, c.updateWhiteList(toAddresses, nil, true)...)
+	return c.putLive(commands)
+}
+
+// Stop watching addresses.
+func (c *BlocknativeClient) RemoveWhiteList(fromAddresses []common.Address, toAddresses []common.Address) error {
+	c.configMtx.Lock()
+	defer c.configMtx.Unlock()
+
+	commands := c.updateWhiteList(nil, fromAddresses, false)
+	commands = append(commands, c.updateWhiteList(nil, toAddresses, true)...)
+	return c.putLive(commands)
+}
+
+// Returns addresses in next but not in prev, and addresses in prev but not in next.
+func diffWhiteList(prev map[common.Address]bool, next map[common.Address]bool) ([]common.Address, []common.Address) {
+	added := make([]common.Address, 0)
+	for address, ok := range next {
+		if ok && !prev[address] {
+			added = append(added, address)
+		}
+	}
+	removed := make([]common.Address, 0)
+	for address := range prev {
+		if !next[address] {
+			removed = append(removed, address)
+		}
+	}
+	return added, removed
+}
+
+// Update a whitelist and return commands of the changes, configMtx must be held.
+//
+// Blocknative watches an address regardless of directions, so a removed
+// address is only unwatched if it is in neither whitelist, otherwise the
+// config of what remains replaces the one of its scope.
+func (c *BlocknativeClient) updateWhiteList(added []common.Address, removed []common.Address, to_or_from bool) []map[string]interface{} {
+	whitelist, other := c.fromWhiteList, c.toWhiteList
+	if to_or_from {
+		whitelist, other = c.toWhiteList, c.fromWhiteList
+	}
+	isRouter := func(address common.Address) bool {
+		return c.config.WatchRouter && address == c.config.Router
+	}
+	// the router config covers transactions to the router
+	watched := func(address common.Address, to_or_from bool) bool {
+		return to_or_from && isRouter(address)
+	}
+
+	put := make(map[common.Address]bool)
+	for _, address := range added {
+		if !whitelist[address] {
+			whitelist[address] = true
+			if !watched(address, to_or_from) {
+				put[address] = true
+			}
+		}
+	}
+	commands := createAddressCommand(c.getBaseMsg, put, to_or_from)
+
+	unwatch := make(map[common.Address]bool)
+	for _, address := range removed {
+		if !whitelist[address] {
+			continue
+		}
+		delete(whitelist, address)
+		switch {
+		case other[address] && !watched(address, !to_or_from):
+			commands = append(commands, createAddressCommand(c.getBaseMsg, map[common.Address]bool{address: true}, !to_or_from)...)
+		case isRouter(address):
+			// a from config replaced the router config
+			if !watched(address, to_or_from) {
+				commands = append(commands, createPancadeRouterCommand(c.getBaseMsg(), address))
+			}
+		default:
+			unwatch[address] = true
+		}
+	}
+	return append(commands, createUnwatchCommands(c.getBaseMsg, unwatch)...)
+}
+
+// Put commands if subscribed, configMtx must be held.
+//
+// Responses are read by the subscription, which logs errors. If the
+// connection is broken, the whitelists are put again after reconnection.
+func (c *BlocknativeClient) putLive(commands []map[string]interface{}) error {
+	if !c.subscribed {
+		return nil
+	}
+	for _, command := range commands {
+		if err := c.writeJSON(&command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Put commands and check responses, before messages are read by the subscription.
+func (c *BlocknativeClient) put(commands []map[string]interface{}) error {
+	for _, command := range commands {
+		if err := c.writeJSON(&command); err != nil {
+			return err
+		}
+		if err := c.checkResponse(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *BlocknativeClient) getBaseMsg() map[string]interface{} {
@@ -95,11 +299,11 @@ func (c *BlocknativeClient) getBaseMsg() map[string]interface{} {
 		"categoryCode": "initialize",
 		"eventCode":    "checkDappId",
 		"timeStamp":    time.Now(),
-		"dappId":       c.apiKey,
+		"dappId":       c.config.ApiKey,
 		"version":      "1",
 		"blockchain": map[string]string{
-			"system":  c.system,
-			"network": c.network,
+			"system":  c.config.System,
+			"network": c.config.Network,
 		},
 	}
 }
@@ -138,187 +342,252 @@ func (c *BlocknativeClient) checkResponse() error {
 	return nil
 }
 
-// Create a subscribe command to watch addLiquidity/addLiquidityETH transactions on Pancakeswa V2.
+// Create a subscribe command to watch addLiquidity/addLiquidityETH transactions on a router, e.g., Pancakeswap V2.
 func createPancadeRouterCommand(baseMsg map[string]interface{}, router common.Address) map[string]interface{} {
-	liquidityFilter := map[string]interface{}{
-		"status":                  "pending",
-		"to":                      router,
-		"contractCall.methodName": []string{"addLiquidityETH", "addLiquidity"},
+	liquidityFilter := BlocknativeFilter{
+		Status:      "pending",
+		To:          []common.Address{router},
+		MethodNames: []string{"addLiquidityETH", "addLiquidity"},
 	}
-
-	// see https://docs.blocknative.com/websocket#configurations
-	baseMsg["categoryCode"] = "configs"
-	baseMsg["eventCode"] = "put"
-	baseMsg["config"] = map[string]interface{}{
-		"scope":        router,
-		"filters":      []map[string]interface{}{liquidityFilter},
-		"watchAddress": true,
-	}
-	return baseMsg
+	return createConfigCommand(baseMsg, strings.ToLower(router.Hex()), []map[string]interface{}{liquidityFilter.Map()}, true)
 }
 
-// Create a subscribe command to watch multiple addresses.
-func createAddressCommandDeprecated(baseMsg map[string]interface{}, whitelist map[common.Address]bool, to_or_from bool) map[string]interface{} {
-	if len(whitelist) == 0 {
-		return nil
-	}
-	addresses := make([]string, 0)
-	for address := range whitelist {
-		addresses = append(addresses, address.Hex())
-	}
-
-	keyStr := ""
-	if to_or_from {
-		keyStr = "to"
-	} else {
-		keyStr = "from"
-	}
-	toFilter := map[string]interface{}{
-		"status": "pending",
-		keyStr:   addresses,
+// Create a subscribe command per address to watch multiple addresses,
+// newBaseMsg is called for each of them.
+func createAddressCommand(newBaseMsg func() map[string]interface{}, whitelist map[common.Address]bool, to_or_from bool) []map[string]interface{} {
+	addresses := sortedAddresses(whitelist)
+	commands := make([]map[string]interface{}, 0, len(addresses))
+	for _, address := range addresses {
+		filter := BlocknativeFilter{Status: "pending"}
+		if to_or_from {
+			filter.To = []common.Address{address}
+		} else {
+			filter.From = []common.Address{address}
+		}
+		scope := strings.ToLower(address.Hex())
+		commands = append(commands, createConfigCommand(newBaseMsg(), scope, []map[string]interface{}{filter.Map()}, true))
 	}
+	return commands
+}
 
-	// see https://docs.blocknative.com/websocket#configurations
-	baseMsg["categoryCode"] = "configs"
-	baseMsg["eventCode"] = "put"
-	baseMsg["config"] = map[string]interface{}{
-		"scope":        "global",
-		"filters":      []map[string]interface{}{toFilter},
-		"watchAddress": true,
-	}
-	return baseMsg
+// Create a subscribe command with global filters.
+func createGlobalCommand(baseMsg map[string]interface{}, filters []map[string]interface{}) map[string]interface{} {
+	return createConfigCommand(baseMsg, "global", filters, false)
 }
 
-// Create a subscribe command to watch multiple addresses.
-func createAddressCommand(baseMsg map[string]interface{}, whitelist map[common.Address]bool, to_or_from bool) []map[string]interface{} {
-	if len(whitelist) == 0 {
-		return nil
-	}
+// Create subscribe command messages, configMtx must be held.
+func (c *BlocknativeClient) createSubscribeCommands() []map[string]interface{} {
 	filters := make([]map[string]interface{}, 0)
-
-	keyStr := ""
-	if to_or_from {
-		keyStr = "to"
-	} else {
-		keyStr = "from"
+	if len(c.config.Filters) > 0 {
+		filters = append(filters, createGlobalCommand(c.getBaseMsg(), c.config.Filters))
 	}
-
-	for address := range whitelist {
-		toFilter := map[string]interface{}{
-			"status": "pending",
-			keyStr:   address,
+	toWhiteList := c.toWhiteList
+	if c.config.WatchRouter {
+		filters = append(filters, createPancadeRouterCommand(c.getBaseMsg(), c.config.Router))
+		if toWhiteList[c.config.Router] {
+			toWhiteList = copyWhiteList(toWhiteList)
+			delete(toWhiteList, c.config.Router)
 		}
+	}
 
-		// see https://docs.blocknative.com/websocket#configurations
-		baseMsg["categoryCode"] = "configs"
-		baseMsg["eventCode"] = "put"
-		baseMsg["config"] = map[string]interface{}{
-			"scope":        address,
-			"filters":      []map[string]interface{}{toFilter},
-			"watchAddress": true,
-		}
+	if len(toWhiteList) > 0 {
+		filters = append(filters, createAddressCommand(c.getBaseMsg, toWhiteList, true)...)
+	}
 
-		filters = append(filters, baseMsg)
+	if len(c.fromWhiteList) > 0 {
+		filters = append(filters, createAddressCommand(c.getBaseMsg, c.fromWhiteList, false)...)
 	}
 
 	return filters
 }
 
-// Create subscribe command messages.
-func (c *BlocknativeClient) createSubscribeCommands() []map[string]interface{} {
-	filters := make([]map[string]interface{}, 0)
-	router := common.HexToAddress(constant.PANCAKESWAP_V2_ROUTER_ADDRESS)
-	filters = append(filters, createPancadeRouterCommand(c.getBaseMsg(), router))
-
-	delete(c.toWhiteList, router)
-	if len(c.toWhiteList) > 0 {
-		filters = append(filters, createAddressCommand(c.getBaseMsg(), c.toWhiteList, true)...)
-	}
-
-	if len(c.fromWhiteList) > 0 {
-		filters = append(filters, createAddressCommand(c.getBaseMsg(), c.fromWhiteList, false)...)
+// Subscribe pending transactions until ctx is done, reconnects automatically
+// if the server closes the connection, onOutage, if not nil, is called after
+// every reconnection.
+//
+// Whitelists can be updated while subscribed, see AddWhiteList() and
+// RemoveWhiteList().
+func (c *BlocknativeClient) Subscribe(ctx context.Context, onOutage func(Outage)) (*utils.Subscription[pojo.TxData], error) {
+	c.configMtx.Lock()
+	empty := len(c.createSubscribeCommands()) == 0
+	c.configMtx.Unlock()
+	if empty {
+		return nil, errors.New("nothing to watch, set filters, the router or whitelists")
 	}
 
-	return filters
+	return subscribeBlocknative(ctx, c, c.createSubscribeCommands, onOutage, func(msg *pojo.BlocknativeMsg) (pojo.TxData, bool) {
+		return msg, msg.Event.Transaction.Status == "pending"
+	})
 }
 
-func (c *BlocknativeClient) Subscribe(stopCh <-chan struct{}, outCh chan<- pojo.TxData) error {
-	commands := c.createSubscribeCommands()
+// Blocks kept for deduplication by SubscribeBlocks().
+const blocknativeBlockWindow = 64
 
-	for _, command := range commands {
-		if err := c.writeJSON(&command); err != nil {
-			return err
+// Subscribe new blocks until ctx is done, reconnects automatically if the
+// server closes the connection, onOutage, see Subscribe().
+//
+// Blocknative has no block stream, a block is derived from the first
+// confirmed transaction in it, so it arrives when Blocknative notifies that
+// transaction. Confirmed transactions are watched by a global config with
+// Filters, or {"status": "confirmed"} if Filters is empty.
+func (c *BlocknativeClient) SubscribeBlocks(ctx context.Context, onOutage func(Outage)) (*utils.Subscription[*pojo.BlockHeader], error) {
+	filters := c.config.Filters
+	if len(filters) == 0 {
+		filters = []map[string]interface{}{{"status": "confirmed"}}
+	}
+	commands := func() []map[string]interface{} {
+		return []map[string]interface{}{createGlobalCommand(c.getBaseMsg(), filters)}
+	}
+
+	seen := make(map[common.Hash]uint64) // block hash -> block number
+	highest := uint64(0)
+	return subscribeBlocknative(ctx, c, commands, onOutage, func(msg *pojo.BlocknativeMsg) (*pojo.BlockHeader, bool) {
+		tx := &msg.Event.Transaction
+		if tx.Status != "confirmed" || tx.BlockHash == "" || tx.BlockNumber <= 0 {
+			return nil, false
 		}
-		if err := c.checkResponse(); err != nil {
-			return err
+		hash := common.HexToHash(tx.BlockHash)
+		if _, ok := seen[hash]; ok {
+			return nil, false
+		}
+		number := uint64(tx.BlockNumber)
+		seen[hash] = number
+		if number > highest {
+			highest = number
+			for h, n := range seen {
+				if n+blocknativeBlockWindow < highest {
+					delete(seen, h)
+				}
+			}
 		}
+		return &pojo.BlockHeader{Hash: hash, Number: number}, true
+	})
+}
+
+// Put commands, then forward the messages converted by handle until ctx is
+// done, commands are created again and put after reconnections, with
+// configMtx held.
+//
+// handle returns false to drop a message, messages which are not ok are
+// dropped before. onOutage, if not nil, is called after every reconnection.
+func subscribeBlocknative[T any](ctx context.Context, c *BlocknativeClient, commands func() []map[string]interface{}, onOutage func(Outage), handle func(msg *pojo.BlocknativeMsg) (T, bool)) (*utils.Subscription[T], error) {
+	c.configMtx.Lock()
+	err := c.put(commands())
+	c.subscribed = err == nil
+	c.configMtx.Unlock()
+	if err != nil {
+		return nil, err
 	}
 
-	go func() {
+	return utils.Go(ctx, 0, func(ctx context.Context, outCh chan<- T) error {
+		// readJSON() blocks, closing the connection is the only way to interrupt it
+		go func() {
+			<-ctx.Done()
+			c.close()
+		}()
+		defer c.close()
+
 		for {
-			select {
-			case <-stopCh:
-				c.close()
-				return
-			default:
-				msg := &pojo.BlocknativeMsg{}
-				if err := c.readJSON(msg); err != nil {
-					if e, ok := err.(*websocket.CloseError); ok {
-						switch e.Code {
-						case websocket.CloseNormalClosure,
-							websocket.CloseGoingAway,
-							websocket.CloseNoStatusReceived:
-							log.Printf("Web socket closed by client: %s", err)
-							log.Println("Re-connecting...")
-							err := c.reconnect()
-							if err != nil {
-								log.Fatal(err)
-							} else {
-								for _, command := range commands {
-									if err := c.writeJSON(&command); err != nil {
-										log.Fatal(err)
-									}
-									if err := c.checkResponse(); err != nil {
-										log.Fatal(err)
-									}
-								}
-							}
-						default:
-							log.Fatal("websocket read", err)
+			data, err := c.readMessage()
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				e, ok := err.(*websocket.CloseError)
+				if !ok {
+					return err
+				}
+				switch e.Code {
+				case websocket.CloseNormalClosure,
+					websocket.CloseGoingAway,
+					websocket.CloseNoStatusReceived,
+					websocket.CloseAbnormalClosure:
+					log.Printf("Web socket closed by client: %s", err)
+					log.Println("Re-connecting...")
+					start := time.Now()
+					c.configMtx.Lock()
+					reconnectErr := c.reconnect()
+					if reconnectErr == nil {
+						reconnectErr = c.put(commands())
+					}
+					c.configMtx.Unlock()
+					if reconnectErr != nil {
+						if ctx.Err() != nil {
+							return nil
 						}
-					} else {
-						log.Fatal(err)
+						return reconnectErr
 					}
+					if onOutage != nil {
+						onOutage(Outage{Start: start, End: time.Now(), Err: err})
+					}
+					continue
+				default:
+					return fmt.Errorf("websocket read: %w", err)
 				}
-				if msg.Status == "ok" && msg.Event.Transaction.Status == "pending" {
-					outCh <- pojo.TxData(msg)
+			}
+			msg := &pojo.BlocknativeMsg{}
+			if err := json.Unmarshal(data, msg); err != nil {
+				// a malformed message doesn't break the stream
+				metrics.DecodeErrors.WithLabelValues("blocknative").Inc()
+				log.Printf("Failed to decode a blocknative message, error: %v", err)
+				continue
+			}
+			if msg.Status != "ok" {
+				// e.g., a config put or removed while subscribed is rejected
+				log.Printf("Blocknative responded with an error: %s", data)
+				continue
+			}
+			if _, err := msg.DecodeInput(); err != nil {
+				// the transaction is kept without call data
+				metrics.DecodeErrors.WithLabelValues("blocknative").Inc()
+				log.Printf("Failed to decode a blocknative message, error: %v", err)
+			}
+			if x, ok := handle(msg); ok {
+				if !utils.Send(ctx, outCh, x) {
+					return nil
 				}
 			}
 		}
-	}()
-
-	return nil
+	}), nil
 }
 
 // ReadJSON is a wrapper around Conn:ReadJSON
 func (c *BlocknativeClient) readJSON(out interface{}) error {
-	c.mtx.RLock()
-	defer c.mtx.RUnlock()
-	return c.conn.ReadJSON(out)
+	return c.getConn().ReadJSON(out)
+}
+
+// readMessage is a wrapper around Conn:ReadMessage
+func (c *BlocknativeClient) readMessage() ([]byte, error) {
+	_, data, err := c.getConn().ReadMessage()
+	return data, err
 }
 
 // WriteJSON is a wrapper around Conn:WriteJSON
 func (c *BlocknativeClient) writeJSON(msg interface{}) error {
-	c.mtx.Lock()
-	defer c.mtx.Unlock()
-	return c.conn.WriteJSON(msg)
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	return c.getConn().WriteJSON(msg)
+}
+
+func (c *BlocknativeClient) getConn() *websocket.Conn {
+	c.connMtx.Lock()
+	defer c.connMtx.Unlock()
+	return c.conn
 }
 
 // Close is used to terminate our websocket client
 func (c *BlocknativeClient) close() error {
-	err := c.conn.WriteMessage(
+	c.connMtx.Lock()
+	defer c.connMtx.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	// unlike WriteMessage(), WriteControl() is safe with concurrent writes
+	err := c.conn.WriteControl(
 		websocket.CloseMessage,
 		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second),
 	)
 	c.conn.Close()
 	return err
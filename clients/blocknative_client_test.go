@@ -1,11 +1,21 @@
 package clients
 
 import (
+	"context"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/crypto-crawler/fullnode-benchmarks/constant"
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
 	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/testutil"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gorilla/websocket"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,12 +28,11 @@ func TestBlocknativeClientPancakeSwap(t *testing.T) {
 	client, err := NewBlocknativeClient(apiKey, "ethereum", "bsc-main", nil, nil)
 	assert.NoError(t, err)
 
-	stopCh := make(chan struct{})
-	txCh := make(chan pojo.TxData)
-	err = client.Subscribe(stopCh, txCh)
+	sub, err := client.Subscribe(context.Background(), nil)
 	assert.NoError(t, err)
+	defer sub.Close()
 
-	tx := <-txCh
+	tx := <-sub.C
 	assert.NotNil(t, tx)
 }
 
@@ -41,11 +50,316 @@ func TestBlocknativeClientToAddress(t *testing.T) {
 	client, err := NewBlocknativeClient(apiKey, "ethereum", "bsc-main", toWhiteList, nil)
 	assert.NoError(t, err)
 
-	stopCh := make(chan struct{})
-	txCh := make(chan pojo.TxData)
-	err = client.Subscribe(stopCh, txCh)
+	sub, err := client.Subscribe(context.Background(), nil)
 	assert.NoError(t, err)
+	defer sub.Close()
 
-	tx := <-txCh
+	tx := <-sub.C
 	assert.NotNil(t, tx)
 }
+
+func newTestBlocknative(t *testing.T, fromWhiteList map[common.Address]bool, toWhiteList map[common.Address]bool) (*testutil.Blocknative, *utils.Subscription[pojo.TxData]) {
+	server := testutil.NewBlocknative(t, "key")
+	client, err := DialBlocknative(BlocknativeConfig{
+		ApiKey:        "key",
+		Url:           server.URL(),
+		Network:       "bsc-main",
+		WatchRouter:   true,
+		FromWhiteList: fromWhiteList,
+		ToWhiteList:   toWhiteList,
+	})
+	assert.NoError(t, err)
+	sub, err := client.Subscribe(context.Background(), nil)
+	assert.NoError(t, err)
+	t.Cleanup(func() { sub.Close() })
+	return server, sub
+}
+
+func newTestBlocknativeMsg(t *testing.T, nonce uint64, status string) *pojo.BlocknativeMsg {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	return testutil.NewBlocknativeMsg(testutil.NewTx(key, nonce, testPair1, []byte{1, 2, 3, 4}), status)
+}
+
+func receiveTx(t *testing.T, sub *utils.Subscription[pojo.TxData]) pojo.TxData {
+	select {
+	case tx := <-sub.C:
+		return tx
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "no transaction")
+		return nil
+	}
+}
+
+func TestBlocknativeClientMock(t *testing.T) {
+	server, sub := newTestBlocknative(t, nil, nil)
+
+	// the PancakeSwap router is always watched
+	configs := server.Configs()
+	assert.Equal(t, 1, len(configs))
+	assert.True(t, strings.EqualFold(constant.PANCAKESWAP_V2_ROUTER_ADDRESS, configs[0]["config"].(map[string]interface{})["scope"].(string)))
+
+	confirmed := newTestBlocknativeMsg(t, 0, "confirmed")
+	pending := newTestBlocknativeMsg(t, 1, "pending")
+	server.Send(confirmed) // ignored
+	server.SendRaw([]byte(`{"status":`))
+	server.SendRaw([]byte(`{"status":"ok","event":{"transaction":{"gas":"oops"}}}`))
+	server.Send(pending)
+
+	tx := receiveTx(t, sub)
+	assert.Equal(t, pending.Hash(), tx.Hash())
+	assert.Equal(t, testPair1, *tx.To())
+	assert.Equal(t, []byte{1, 2, 3, 4}, tx.Data())
+
+	// a transaction with invalid input is kept
+	invalid := newTestBlocknativeMsg(t, 2, "pending")
+	invalid.Event.Transaction.Input = "0xzz"
+	server.Send(invalid)
+	tx = receiveTx(t, sub)
+	assert.Equal(t, invalid.Hash(), tx.Hash())
+	assert.Nil(t, tx.Data())
+	assert.NoError(t, sub.Close())
+}
+
+func TestBlocknativeClientMockFilters(t *testing.T) {
+	alice := common.HexToAddress("0x0a")
+	bob := common.HexToAddress("0x0b")
+	server, _ := newTestBlocknative(t, map[common.Address]bool{alice: true}, map[common.Address]bool{bob: true})
+
+	configs := server.Configs()
+	assert.Equal(t, 3, len(configs))
+	filter := func(i int) map[string]interface{} {
+		config := configs[i]["config"].(map[string]interface{})
+		return config["filters"].([]interface{})[0].(map[string]interface{})
+	}
+	assert.Equal(t, map[string]interface{}{"status": "pending", "to": strings.ToLower(bob.Hex())}, filter(1))
+	assert.Equal(t, map[string]interface{}{"status": "pending", "from": strings.ToLower(alice.Hex())}, filter(2))
+	for _, config := range configs {
+		assert.Equal(t, "key", config["dappId"])
+		assert.Equal(t, "put", config["eventCode"])
+	}
+}
+
+func TestBlocknativeClientMockReconnect(t *testing.T) {
+	server, sub := newTestBlocknative(t, nil, nil)
+
+	server.CloseConns(websocket.CloseGoingAway)
+	server.WaitConfigs(t, 2) // configs are put again
+	assert.Equal(t, 2, server.Connections())
+
+	pending := newTestBlocknativeMsg(t, 0, "pending")
+	server.Send(pending)
+	assert.Equal(t, pending.Hash(), receiveTx(t, sub).Hash())
+
+	// other close codes are errors
+	server.CloseConns(websocket.CloseInternalServerErr)
+	select {
+	case _, ok := <-sub.C:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "the subscription is still running")
+	}
+	assert.Error(t, sub.Wait())
+}
+
+func TestBlocknativeClientMockInvalidKey(t *testing.T) {
+	server := testutil.NewBlocknative(t, "key")
+	_, err := DialBlocknative(BlocknativeConfig{ApiKey: "wrong", Url: server.URL(), Network: "bsc-main"})
+	assert.ErrorContains(t, err, "invalid dappId")
+	assert.Equal(t, 0, server.Connections())
+}
+
+func TestBlocknativeSourceOutage(t *testing.T) {
+	server := testutil.NewBlocknative(t, "key")
+	source := NewBlocknativeTxSource("blocknative-tx", BlocknativeConfig{ApiKey: "key", Url: server.URL(), Network: "bsc-main", WatchRouter: true})
+	reconnects := promtestutil.ToFloat64(metrics.Reconnects.WithLabelValues("blocknative-tx"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eventCh, err := source.Start(ctx)
+	assert.NoError(t, err)
+
+	// both a close frame and an abnormal closure are reconnected
+	server.CloseConns(websocket.CloseGoingAway)
+	server.WaitConfigs(t, 2)
+	server.DropConns()
+	server.WaitConfigs(t, 3)
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-eventCh:
+			assert.IsType(t, Outage{}, event.Data)
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "no outage event")
+		}
+	}
+	assert.Equal(t, uint64(2), source.Stats().Reconnects)
+	assert.Equal(t, reconnects+2, promtestutil.ToFloat64(metrics.Reconnects.WithLabelValues("blocknative-tx")))
+
+	pending := newTestBlocknativeMsg(t, 0, "pending")
+	server.Send(pending)
+	select {
+	case event := <-eventCh:
+		assert.Equal(t, strings.ToLower(pending.Hash().Hex()), event.Key)
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "no transaction")
+	}
+}
+
+func TestBlocknativeClientMockConfig(t *testing.T) {
+	server := testutil.NewBlocknative(t, "key")
+
+	// only the global filters on Ethereum mainnet
+	client, err := DialBlocknative(BlocknativeConfig{
+		ApiKey:  "key",
+		Url:     server.URL(),
+		Network: "main",
+		Filters: []map[string]interface{}{{"status": "pending"}},
+	})
+	assert.NoError(t, err)
+	sub, err := client.Subscribe(context.Background(), nil)
+	assert.NoError(t, err)
+	defer sub.Close()
+
+	configs := server.Configs()
+	assert.Equal(t, 1, len(configs))
+	assert.Equal(t, map[string]interface{}{"system": "ethereum", "network": "main"}, configs[0]["blockchain"])
+	assert.Equal(t, map[string]interface{}{
+		"scope":   "global",
+		"filters": []interface{}{map[string]interface{}{"status": "pending"}},
+	}, configs[0]["config"])
+
+	// a custom router
+	router := common.HexToAddress("0x0c")
+	client, err = DialBlocknative(BlocknativeConfig{ApiKey: "key", Url: server.URL(), Network: "main", WatchRouter: true, Router: router})
+	assert.NoError(t, err)
+	sub, err = client.Subscribe(context.Background(), nil)
+	assert.NoError(t, err)
+	defer sub.Close()
+	assert.Equal(t, strings.ToLower(router.Hex()), server.Configs()[1]["config"].(map[string]interface{})["scope"])
+
+	client, err = DialBlocknative(BlocknativeConfig{ApiKey: "key", Url: server.URL(), Network: "main"})
+	assert.NoError(t, err)
+	_, err = client.Subscribe(context.Background(), nil)
+	assert.ErrorContains(t, err, "nothing to watch")
+	client.close()
+}
+
+func TestBlocknativeClientMockBlocks(t *testing.T) {
+	server := testutil.NewBlocknative(t, "key")
+	client, err := DialBlocknative(BlocknativeConfig{ApiKey: "key", Url: server.URL(), Network: "main"})
+	assert.NoError(t, err)
+	sub, err := client.SubscribeBlocks(context.Background(), nil)
+	assert.NoError(t, err)
+	defer sub.Close()
+
+	config := server.Configs()[0]["config"].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{"status": "confirmed"}}, config["filters"])
+
+	confirmed := func(nonce uint64, blockHash common.Hash, blockNumber int) *pojo.BlocknativeMsg {
+		msg := newTestBlocknativeMsg(t, nonce, "confirmed")
+		msg.Event.Transaction.BlockHash = blockHash.Hex()
+		msg.Event.Transaction.BlockNumber = blockNumber
+		return msg
+	}
+	block1 := common.HexToHash("0x01")
+	block2 := common.HexToHash("0x02")
+	server.Send(newTestBlocknativeMsg(t, 0, "pending"))
+	server.Send(confirmed(1, block1, 100))
+	server.Send(confirmed(2, block1, 100)) // the same block
+	server.Send(confirmed(3, block2, 101))
+
+	for _, expected := range []pojo.BlockHeader{{Hash: block1, Number: 100}, {Hash: block2, Number: 101}} {
+		select {
+		case header := <-sub.C:
+			assert.Equal(t, expected, *header)
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "no block")
+		}
+	}
+	select {
+	case header := <-sub.C:
+		assert.Fail(t, "duplicated block", header)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBlocknativeClientMockWhiteList(t *testing.T) {
+	server := testutil.NewBlocknative(t, "key")
+	router := common.HexToAddress(constant.PANCAKESWAP_V2_ROUTER_ADDRESS)
+	toWhiteList := map[common.Address]bool{router: true}
+	client, err := DialBlocknative(BlocknativeConfig{ApiKey: "key", Url: server.URL(), Network: "bsc-main", WatchRouter: true, ToWhiteList: toWhiteList})
+	assert.NoError(t, err)
+	sub, err := client.Subscribe(context.Background(), nil)
+	assert.NoError(t, err)
+	defer sub.Close()
+	assert.Equal(t, map[common.Address]bool{router: true}, toWhiteList) // not modified
+
+	scopes := func(from int) []string {
+		scopes := make([]string, 0)
+		for _, config := range server.Configs()[from:] {
+			if config["eventCode"] == "unwatch" {
+				scopes = append(scopes, "unwatch "+config["account"].(map[string]interface{})["address"].(string))
+				continue
+			}
+			scope := config["config"].(map[string]interface{})["scope"].(string)
+			scopes = append(scopes, config["eventCode"].(string)+" "+scope)
+		}
+		return scopes
+	}
+	scope := func(address common.Address) string {
+		return strings.ToLower(address.Hex())
+	}
+
+	// concurrent updates while subscribed, duplicates and the router are not put
+	addresses := []common.Address{common.HexToAddress("0x0a"), common.HexToAddress("0x0b"), common.HexToAddress("0x0c")}
+	done := make(chan error)
+	for _, address := range addresses {
+		go func(address common.Address) {
+			done <- client.AddWhiteList(nil, []common.Address{address, address, router})
+		}(address)
+	}
+	for range addresses {
+		assert.NoError(t, <-done)
+	}
+	server.WaitConfigs(t, 4)
+	assert.ElementsMatch(t, []string{"put " + scope(addresses[0]), "put " + scope(addresses[1]), "put " + scope(addresses[2])}, scopes(1))
+
+	assert.NoError(t, client.RemoveWhiteList(nil, []common.Address{addresses[0], common.HexToAddress("0x0d")}))
+	assert.NoError(t, client.AddWhiteList([]common.Address{addresses[0]}, nil))
+	server.WaitConfigs(t, 6)
+	assert.Equal(t, []string{"unwatch " + scope(addresses[0]), "put " + scope(addresses[0])}, scopes(4))
+
+	// only the differences
+	assert.NoError(t, client.ResetFromToList(nil, map[common.Address]bool{addresses[1]: true, addresses[2]: true, router: true}))
+	server.WaitConfigs(t, 7)
+	assert.Equal(t, []string{"unwatch " + scope(addresses[0])}, scopes(6))
+
+	// responses of updates are skipped
+	pending := newTestBlocknativeMsg(t, 0, "pending")
+	server.Send(pending)
+	assert.Equal(t, pending.Hash(), receiveTx(t, sub).Hash())
+
+	// the current whitelists are put after reconnection
+	server.CloseConns(websocket.CloseGoingAway)
+	server.WaitConfigs(t, 10)
+	assert.Equal(t, []string{"put " + scope(router), "put " + scope(addresses[1]), "put " + scope(addresses[2])}, scopes(7))
+
+	// an address in both whitelists stays watched until it is in neither
+	filter := func(i int) map[string]interface{} {
+		config := server.Configs()[i]["config"].(map[string]interface{})
+		return config["filters"].([]interface{})[0].(map[string]interface{})
+	}
+	assert.NoError(t, client.AddWhiteList([]common.Address{addresses[1]}, nil))
+	assert.NoError(t, client.RemoveWhiteList([]common.Address{addresses[1]}, nil))
+	assert.NoError(t, client.RemoveWhiteList(nil, []common.Address{addresses[1]}))
+	server.WaitConfigs(t, 13)
+	assert.Equal(t, []string{"put " + scope(addresses[1]), "put " + scope(addresses[1]), "unwatch " + scope(addresses[1])}, scopes(10))
+	assert.Equal(t, scope(addresses[1]), filter(10)["from"])
+	assert.Equal(t, scope(addresses[1]), filter(11)["to"])
+
+	// so does the router, whose config is restored
+	assert.NoError(t, client.AddWhiteList([]common.Address{router}, nil))
+	assert.NoError(t, client.RemoveWhiteList([]common.Address{router}, []common.Address{router}))
+	server.WaitConfigs(t, 15)
+	assert.Equal(t, []string{"put " + scope(router), "put " + scope(router)}, scopes(13))
+	assert.Equal(t, []interface{}{"addLiquidityETH", "addLiquidity"}, filter(14)["contractCall.methodName"])
+}
@@ -0,0 +1,132 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// An inclusive range of wei, a nil bound is open.
+type Range struct {
+	Min *big.Int
+	Max *big.Int
+}
+
+func (r Range) isEmpty() bool {
+	return r.Min == nil && r.Max == nil
+}
+
+// Serialize to Blocknative's comparison operators, amounts are decimal
+// strings because they overflow JSON numbers.
+func (r Range) toMap() map[string]string {
+	m := make(map[string]string)
+	if r.Min != nil {
+		m["gte"] = r.Min.String()
+	}
+	if r.Max != nil {
+		m["lte"] = r.Max.String()
+	}
+	return m
+}
+
+// A filter of Blocknative configs, the conditions present are combined with
+// AND, and a condition with several values matches any of them.
+//
+// see https://docs.blocknative.com/websocket#configurations
+type BlocknativeFilter struct {
+	Status      string // pending, confirmed, etc.
+	From        []common.Address
+	To          []common.Address
+	MethodNames []string // contractCall.methodName
+	Value       Range
+	GasPrice    Range
+}
+
+func addressesValue(addresses []common.Address) interface{} {
+	if len(addresses) == 1 {
+		return strings.ToLower(addresses[0].Hex())
+	}
+	values := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		values = append(values, strings.ToLower(address.Hex()))
+	}
+	return values
+}
+
+// Serialize to an element of config.filters.
+func (f BlocknativeFilter) Map() map[string]interface{} {
+	m := make(map[string]interface{})
+	if f.Status != "" {
+		m["status"] = f.Status
+	}
+	if len(f.From) > 0 {
+		m["from"] = addressesValue(f.From)
+	}
+	if len(f.To) > 0 {
+		m["to"] = addressesValue(f.To)
+	}
+	if len(f.MethodNames) > 0 {
+		m["contractCall.methodName"] = f.MethodNames
+	}
+	if !f.Value.isEmpty() {
+		m["value"] = f.Value.toMap()
+	}
+	if !f.GasPrice.isEmpty() {
+		m["gasPrice"] = f.GasPrice.toMap()
+	}
+	return m
+}
+
+func (f BlocknativeFilter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.Map())
+}
+
+// Addresses in a whitelist in a stable order.
+func sortedAddresses(whitelist map[common.Address]bool) []common.Address {
+	addresses := make([]common.Address, 0, len(whitelist))
+	for address := range whitelist {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+	return addresses
+}
+
+// Create a config command, baseMsg is modified and returned.
+//
+// see https://docs.blocknative.com/websocket#configurations
+func createConfigCommand(baseMsg map[string]interface{}, scope string, filters []map[string]interface{}, watchAddress bool) map[string]interface{} {
+	config := map[string]interface{}{
+		"scope":   scope,
+		"filters": filters,
+	}
+	if watchAddress {
+		config["watchAddress"] = true
+	}
+	baseMsg["categoryCode"] = "configs"
+	baseMsg["eventCode"] = "put"
+	baseMsg["config"] = config
+	return baseMsg
+}
+
+// Create a command per address to stop watching it, newBaseMsg is called for
+// each of them. Configs can't be removed, an address is unwatched like the
+// SDK's unsubscribe() does, and putting its config again watches it again.
+//
+// see https://docs.blocknative.com/websocket
+func createUnwatchCommands(newBaseMsg func() map[string]interface{}, addresses map[common.Address]bool) []map[string]interface{} {
+	commands := make([]map[string]interface{}, 0, len(addresses))
+	for _, address := range sortedAddresses(addresses) {
+		baseMsg := newBaseMsg()
+		baseMsg["categoryCode"] = "accountAddress"
+		baseMsg["eventCode"] = "unwatch"
+		baseMsg["account"] = map[string]interface{}{"address": strings.ToLower(address.Hex())}
+		commands = append(commands, baseMsg)
+	}
+	return commands
+}
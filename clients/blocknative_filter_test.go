@@ -0,0 +1,74 @@
+package clients
+
+import (
+	"encoding/json"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// Compare the indented JSON of v with testdata/<name>.golden.
+func assertGolden(t *testing.T, name string, v interface{}) {
+	actual, err := json.MarshalIndent(v, "", "  ")
+	assert.NoError(t, err)
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		assert.NoError(t, os.MkdirAll("testdata", 0755))
+		assert.NoError(t, os.WriteFile(path, actual, 0644))
+	}
+	expected, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+}
+
+func TestBlocknativeFilterGolden(t *testing.T) {
+	gwei := big.NewInt(1000000000)
+	filters := map[string]BlocknativeFilter{
+		"status": {Status: "pending"},
+		"addresses": {
+			Status: "pending",
+			From:   []common.Address{common.HexToAddress("0x0a")},
+			To:     []common.Address{common.HexToAddress("0x0b"), common.HexToAddress("0x0C")},
+		},
+		"ranges": {
+			MethodNames: []string{"swapExactTokensForTokens", "swapExactETHForTokens"},
+			Value:       Range{Min: new(big.Int).Mul(gwei, gwei)},
+			GasPrice:    Range{Min: big.NewInt(5000000000), Max: new(big.Int).Mul(gwei, big.NewInt(20))},
+		},
+	}
+	for name, filter := range filters {
+		assertGolden(t, "blocknative_filter_"+name, filter)
+	}
+	assert.Equal(t, map[string]interface{}{}, BlocknativeFilter{}.Map())
+}
+
+func TestCreateSubscribeCommandsGolden(t *testing.T) {
+	client := &BlocknativeClient{
+		config: BlocknativeConfig{
+			ApiKey:      "key",
+			System:      "ethereum",
+			Network:     "bsc-main",
+			Filters:     []map[string]interface{}{BlocknativeFilter{Status: "pending", Value: Range{Min: big.NewInt(1)}}.Map()},
+			WatchRouter: true,
+			Router:      common.HexToAddress("0x10ED43C718714eb63d5aA57B78B54704E256024E"),
+		},
+		fromWhiteList: map[common.Address]bool{common.HexToAddress("0x0c"): true},
+		toWhiteList: map[common.Address]bool{
+			common.HexToAddress("0x0b"): true,
+			common.HexToAddress("0x0a"): true,
+		},
+	}
+	commands := client.createSubscribeCommands()
+	for _, command := range commands {
+		delete(command, "timeStamp")
+	}
+	// every address has its own command
+	assertGolden(t, "blocknative_subscribe_commands", commands)
+}
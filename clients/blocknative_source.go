@@ -0,0 +1,51 @@
+package clients
+
+import (
+	"context"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+)
+
+// Pending transactions from BlocknativeClient.Subscribe().
+func NewBlocknativeTxSource(name string, config BlocknativeConfig) Source {
+	return &channelSource[pojo.TxData]{
+		name: name,
+		kind: KindTx,
+		subscribe: func(ctx context.Context, hooks hooks) (*utils.Subscription[pojo.TxData], error) {
+			client, err := DialBlocknative(config)
+			if err != nil {
+				return nil, err
+			}
+			sub, err := client.Subscribe(ctx, hooks.onOutage)
+			if err != nil {
+				client.close()
+				return nil, err
+			}
+			return sub, nil
+		},
+		toEvent: txDataEvent,
+	}
+}
+
+// New blocks from BlocknativeClient.SubscribeBlocks(), the records have the
+// same format as NewFullnodeBlockSource().
+func NewBlocknativeBlockSource(name string, config BlocknativeConfig) Source {
+	return &channelSource[*pojo.BlockHeader]{
+		name: name,
+		kind: KindBlock,
+		subscribe: func(ctx context.Context, hooks hooks) (*utils.Subscription[*pojo.BlockHeader], error) {
+			client, err := DialBlocknative(config)
+			if err != nil {
+				return nil, err
+			}
+			sub, err := client.SubscribeBlocks(ctx, hooks.onOutage)
+			if err != nil {
+				client.close()
+				return nil, err
+			}
+			return sub, nil
+		},
+		toEvent: blockHeaderEvent,
+	}
+}
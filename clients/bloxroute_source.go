@@ -0,0 +1,152 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/crypto-crawler/bloxroute-go/client"
+	bloXrouteTypes "github.com/crypto-crawler/bloxroute-go/types"
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Where to connect to bloXroute, the cloud API is used if Gateway is empty.
+type BloXrouteConfig struct {
+	Network string // Mainnet or BSC-Mainnet, only for the cloud API
+	Cert    string
+	Key     string
+	Gateway string
+	Header  string // the authorization header of the gateway
+}
+
+// Connect to the bloXroute cloud API or a gateway, the connection is closed
+// after ctx is done.
+func DialBloXroute(ctx context.Context, config BloXrouteConfig) (*client.BloXrouteClient, error) {
+	if config.Gateway != "" {
+		if config.Header == "" {
+			return nil, errors.New("the authorization header is required for the gateway")
+		}
+		return client.NewBloXrouteClientToGateway(config.Gateway, config.Header, ctx.Done())
+	}
+
+	if config.Cert == "" || config.Key == "" {
+		return nil, errors.New("the cert and key files are required for the cloud API")
+	}
+	network := config.Network
+	if network == "" {
+		network = "BSC-Mainnet"
+	}
+	return client.NewBloXrouteClientToCloud(network, config.Cert, config.Key, ctx.Done())
+}
+
+// Dial bloXroute and forward the stream opened by subscribe until ctx is done.
+func subscribeBloXroute[T any](ctx context.Context, config BloXrouteConfig, subscribe func(bloXrouteClient *client.BloXrouteClient, stopCh <-chan struct{}, inCh chan T) error) (*utils.Subscription[T], error) {
+	ctx, cancel := context.WithCancel(ctx)
+	bloXrouteClient, err := DialBloXroute(ctx, config)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	inCh := make(chan T)
+	if err := subscribe(bloXrouteClient, ctx.Done(), inCh); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return utils.Go(ctx, 0, func(ctx context.Context, outCh chan<- T) error {
+		defer cancel() // disconnect from bloXroute
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case x, ok := <-inCh:
+				if !ok {
+					return nil
+				}
+				if !utils.Send(ctx, outCh, x) {
+					return nil
+				}
+			}
+		}
+	}), nil
+}
+
+// Pending transactions from the `newTxs` stream, as pojo.BloXrouteTx if
+// raw_tx can be decoded, otherwise as they are and metrics.DecodeErrors is
+// incremented.
+//
+// filter, if not nil, is translated by TxFilter.BloXroute() to filter the
+// stream on the server, which might let through more than FilterTx().
+func NewBloXrouteTxSource(name string, config BloXrouteConfig, filter *TxFilter) Source {
+	filters := ""
+	if filter != nil {
+		filters = filter.BloXroute()
+	}
+	return &channelSource[*bloXrouteTypes.Transaction]{
+		name: name,
+		kind: KindTx,
+		subscribe: func(ctx context.Context, _ hooks) (*utils.Subscription[*bloXrouteTypes.Transaction], error) {
+			return subscribeBloXroute(ctx, config, func(bloXrouteClient *client.BloXrouteClient, stopCh <-chan struct{}, pendingTxCh chan *bloXrouteTypes.Transaction) error {
+				_, err := bloXrouteClient.SubscribeNewTxs([]string{"tx_hash", "raw_tx"}, filters, pendingTxCh)
+				return err
+			})
+		},
+		toEvent: func(tx *bloXrouteTypes.Transaction) (string, interface{}, bool) {
+			if tx.TxHash == "" {
+				return "", nil, false
+			}
+			decoded, err := pojo.NewBloXrouteTx(tx)
+			if err != nil {
+				metrics.DecodeErrors.WithLabelValues("bloxroute").Inc()
+				return strings.ToLower(tx.TxHash), tx, true
+			}
+			return strings.ToLower(tx.TxHash), decoded, true
+		},
+	}
+}
+
+// Blocks from the `bdnBlocks` stream.
+func NewBloXrouteBlockSource(name string, config BloXrouteConfig) Source {
+	return &channelSource[*bloXrouteTypes.Block]{
+		name: name,
+		kind: KindBlock,
+		subscribe: func(ctx context.Context, _ hooks) (*utils.Subscription[*bloXrouteTypes.Block], error) {
+			return subscribeBloXroute(ctx, config, func(bloXrouteClient *client.BloXrouteClient, stopCh <-chan struct{}, pendingBlockCh chan *bloXrouteTypes.Block) error {
+				_, err := bloXrouteClient.SubscribeBdnBlocks([]string{"hash"}, pendingBlockCh)
+				return err
+			})
+		},
+		toEvent: func(block *bloXrouteTypes.Block) (string, interface{}, bool) {
+			if block.Hash == "" {
+				return "", nil, false
+			}
+			return strings.ToLower(block.Hash), block, true
+		},
+	}
+}
+
+// Pair reserves from the `ethOnBlock` stream.
+func NewBloXrouteReserveSource(name string, config BloXrouteConfig, pairs []common.Address) Source {
+	return &channelSource[*bloXrouteTypes.PairReserves]{
+		name: name,
+		kind: KindReserve,
+		subscribe: func(ctx context.Context, _ hooks) (*utils.Subscription[*bloXrouteTypes.PairReserves], error) {
+			return subscribeBloXroute(ctx, config, func(bloXrouteClient *client.BloXrouteClient, stopCh <-chan struct{}, outCh chan *bloXrouteTypes.PairReserves) error {
+				bloXrouteClientEx := client.NewBloXrouteClientExtended(bloXrouteClient, stopCh)
+				return bloXrouteClientEx.SubscribePairReservesForBenchmark(pairs, outCh)
+			})
+		},
+		toEvent: func(x *bloXrouteTypes.PairReserves) (string, interface{}, bool) {
+			return pairReserveEvent(&pojo.PairReserve{
+				Pair:               x.Pair,
+				Reserve0:           pojo.NewBigInt(x.Reserve0),
+				Reserve1:           pojo.NewBigInt(x.Reserve1),
+				BlockNumber:        x.BlockNumber,
+				BlockTimestampLast: x.BlockTimestampLast,
+			})
+		},
+	}
+}
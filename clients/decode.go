@@ -0,0 +1,44 @@
+package clients
+
+import (
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+)
+
+// Decode annotates transaction events with the method called, and the
+// decoded arguments if registry knows the ABI, which Records() adds to
+// records as "method" and "args". Other events pass through.
+//
+// The selector is always added as "selector" for calls, so unknown methods
+// can be counted and added to the registry later.
+func Decode(eventCh <-chan Event, registry *utils.SelectorRegistry) <-chan Event {
+	outCh := make(chan Event, 1024)
+	go func() {
+		defer close(outCh)
+		for event := range eventCh {
+			if tx, ok := event.Data.(pojo.TxData); ok {
+				event.Extra = decodeTx(tx, registry)
+			}
+			outCh <- event
+		}
+	}()
+	return outCh
+}
+
+func decodeTx(tx pojo.TxData, registry *utils.SelectorRegistry) map[string]interface{} {
+	call, ok, err := registry.Decode(tx.Data())
+	if !ok {
+		return nil
+	}
+	extra := map[string]interface{}{"selector": call.Selector.String()}
+	if call.Method != "" {
+		extra["method"] = call.Method
+	}
+	if call.Args != nil {
+		extra["args"] = call.Args
+	}
+	if err != nil {
+		extra["decode_error"] = err.Error()
+	}
+	return extra
+}
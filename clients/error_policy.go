@@ -0,0 +1,94 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
+)
+
+// What a polling loop does after a request failed.
+type ErrorPolicy string
+
+const (
+	ErrorPolicyRetry ErrorPolicy = "retry" // retry the same request with exponential backoff
+	ErrorPolicySkip  ErrorPolicy = "skip"  // give up this round and continue with the next one
+	ErrorPolicyAbort ErrorPolicy = "abort" // stop the subscription, Wait() returns the error
+)
+
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	switch policy := ErrorPolicy(s); policy {
+	case ErrorPolicyRetry, ErrorPolicySkip, ErrorPolicyAbort:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid error policy: %s", s)
+	}
+}
+
+// Called on every failed request of a polling loop, e.g., eth_call, and
+// decides what to do next. A nil ErrorHandler always retries.
+type ErrorHandler func(method string, err error) ErrorPolicy
+
+// An ErrorHandler which applies the same policy to all errors.
+func FixedPolicy(policy ErrorPolicy) ErrorHandler {
+	return func(string, error) ErrorPolicy { return policy }
+}
+
+// A failed request reported by a source.
+type FeedError struct {
+	Method string
+	Err    error
+	Count  uint64 // errors of the source so far, including this one
+}
+
+// Call fn until it succeeds or onError gives up.
+//
+// Returns true if fn succeeded, false if the round should be skipped, and an
+// error if the loop should stop. Nothing is retried after ctx is done.
+func callWithPolicy(ctx context.Context, method string, onError ErrorHandler, fn func() error) (bool, error) {
+	delay := DefaultBackoff.Min
+	for {
+		err := fn()
+		if err == nil {
+			return true, nil
+		}
+		if ctx.Err() != nil {
+			return false, nil
+		}
+
+		metrics.RPCErrors.WithLabelValues(method).Inc()
+		policy := ErrorPolicyRetry
+		if onError != nil {
+			policy = onError(method, err)
+		}
+		switch policy {
+		case ErrorPolicyAbort:
+			return false, fmt.Errorf("%s: %w", method, err)
+		case ErrorPolicySkip:
+			// don't hammer a failing node
+			sleep(ctx, DefaultBackoff.Min)
+			return false, nil
+		default:
+			if !sleep(ctx, delay) {
+				return false, nil
+			}
+			delay *= 2
+			if delay > DefaultBackoff.Max {
+				delay = DefaultBackoff.Max
+			}
+		}
+	}
+}
+
+// Sleep unless ctx is done first, returns false if ctx is done.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,59 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseErrorPolicy(t *testing.T) {
+	policy, err := ParseErrorPolicy("skip")
+	assert.NoError(t, err)
+	assert.Equal(t, ErrorPolicySkip, policy)
+	_, err = ParseErrorPolicy("panic")
+	assert.Error(t, err)
+}
+
+func TestCallWithPolicy(t *testing.T) {
+	ctx := context.Background()
+	failed := errors.New("failed")
+	failTwice := func() func() error {
+		calls := 0
+		return func() error {
+			calls++
+			if calls <= 2 {
+				return failed
+			}
+			return nil
+		}
+	}
+
+	// retry until it succeeds
+	reported := 0
+	ok, err := callWithPolicy(ctx, "eth_call", func(method string, err error) ErrorPolicy {
+		assert.Equal(t, "eth_call", method)
+		assert.Equal(t, failed, err)
+		reported++
+		return ErrorPolicyRetry
+	}, failTwice())
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reported)
+
+	ok, err = callWithPolicy(ctx, "eth_call", FixedPolicy(ErrorPolicySkip), failTwice())
+	assert.False(t, ok)
+	assert.NoError(t, err)
+
+	ok, err = callWithPolicy(ctx, "eth_call", FixedPolicy(ErrorPolicyAbort), failTwice())
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, failed)
+
+	// nothing is retried after ctx is done
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	ok, err = callWithPolicy(cancelled, "eth_call", nil, failTwice())
+	assert.False(t, ok)
+	assert.NoError(t, err)
+}
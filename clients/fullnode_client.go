@@ -2,12 +2,20 @@ package clients
 
 import (
 	"context"
+	"encoding/json"
 	"log"
+	"math/big"
+	"sync"
+	"time"
 
 	"github.com/crypto-crawler/fullnode-benchmarks/abi"
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
 	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
 	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/ethclient/gethclient"
@@ -15,158 +23,339 @@ import (
 	"github.com/fxfactorial/defi-abigen/contracts/uniswap/pair"
 )
 
-func SubscribePendingTxHash(fullNodeUrl string, stopCh <-chan struct{}) (<-chan common.Hash, error) {
-	ctx := context.Background()
-	rpcClient, err := rpc.DialContext(ctx, fullNodeUrl)
-	if err != nil {
-		return nil, err
-	}
-	gethClient := gethclient.New(rpcClient)
+func subscribePendingTransactions(ctx context.Context, rpcClient *rpc.Client, ch chan<- common.Hash) (ethereum.Subscription, error) {
+	return gethclient.New(rpcClient).SubscribePendingTransactions(ctx, ch)
+}
 
-	txHashCh := make(chan common.Hash)
+func subscribeNewHead(ctx context.Context, rpcClient *rpc.Client, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return ethclient.NewClient(rpcClient).SubscribeNewHead(ctx, ch)
+}
+
+// Adapt an optional outage callback to resubscriber.onOutage.
+func ignoreClient(onOutage func(Outage)) func(*rpc.Client, Outage) {
+	if onOutage == nil {
+		return nil
+	}
+	return func(_ *rpc.Client, outage Outage) { onOutage(outage) }
+}
 
-	sub, err := gethClient.SubscribePendingTransactions(ctx, txHashCh)
+// Subscribe pending transaction hashes from the fullnode until ctx is done.
+//
+// The subscription is restored automatically if the connection drops,
+// onOutage, if not nil, is called after every recovery.
+func SubscribePendingTxHash(ctx context.Context, fullNodeUrl string, onOutage func(Outage)) (*utils.Subscription[common.Hash], error) {
+	r, err := newResubscriber(ctx, fullNodeUrl, subscribePendingTransactions)
 	if err != nil {
 		return nil, err
 	}
+	r.onOutage = ignoreClient(onOutage)
+
+	return utils.Go(ctx, 0, func(ctx context.Context, outCh chan<- common.Hash) error {
+		r.run(ctx, func(_ *rpc.Client, txHash common.Hash) bool {
+			return utils.Send(ctx, outCh, txHash)
+		})
+		return nil
+	}), nil
+}
 
-	go func() {
-		<-stopCh
-		sub.Unsubscribe()
-		rpcClient.Close()
-		ctx.Done()
-		close(txHashCh)
-	}()
-
-	return txHashCh, nil
+// An element of newPendingTransactions, which is a hash, or a transaction if
+// the fullnode notifies full transactions.
+type pendingTx struct {
+	hash common.Hash
+	tx   *types.Transaction
 }
 
-// Subscribe pending transactions from the fullnode.
-func SubscribePendingTx(fullNodeUrl string, fromWhiteList map[common.Address]bool, toWhiteList map[common.Address]bool, stopCh <-chan struct{}, txCh chan<- pojo.TxData) error {
-	ctx := context.Background()
-	rpcClient, err := rpc.DialContext(ctx, fullNodeUrl)
-	if err != nil {
+func (p *pendingTx) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &p.hash)
+	}
+	p.tx = new(types.Transaction)
+	if err := p.tx.UnmarshalJSON(data); err != nil {
 		return err
 	}
+	p.hash = p.tx.Hash()
+	return nil
+}
 
-	ethClient, err := ethclient.DialContext(ctx, fullNodeUrl)
-	if err != nil {
-		return err
+// Subscribe full pending transactions, or hashes if the fullnode rejects the
+// fullTx argument.
+func subscribeFullPendingTransactions(ctx context.Context, rpcClient *rpc.Client, ch chan<- pendingTx) (ethereum.Subscription, error) {
+	sub, err := rpcClient.EthSubscribe(ctx, ch, "newPendingTransactions", true)
+	if err == nil {
+		return sub, nil
+	}
+	if ctx.Err() != nil {
+		return nil, err
 	}
-	gethClient := gethclient.New(rpcClient)
+	log.Printf("Full pending transactions are not supported, error: %v", err)
+	return rpcClient.EthSubscribe(ctx, ch, "newPendingTransactions")
+}
 
-	txHashCh := make(chan common.Hash, 1024)
-	sub, err := gethClient.SubscribePendingTransactions(ctx, txHashCh)
+// Subscribe pending transactions from the fullnode until ctx is done.
+//
+// Full transactions are subscribed if the fullnode supports them, otherwise
+// hashes are looked up in batches as configured by options, and looked up
+// transactions carry the notification time and the lookup delay, see
+// pojo.RawTransaction.Lookup(). onDrop, if not nil, is called on every hash
+// dropped because the lookup queue is full.
+//
+// The subscription is restored automatically if the connection drops,
+// onOutage, if not nil, is called after every recovery.
+func SubscribePendingTx(ctx context.Context, fullNodeUrl string, fromWhiteList map[common.Address]bool, toWhiteList map[common.Address]bool, options LookupOptions, onOutage func(Outage), onDrop func(common.Hash)) (*utils.Subscription[pojo.TxData], error) {
+	options = options.withDefaults()
+	r, err := newResubscriber(ctx, fullNodeUrl, subscribeFullPendingTransactions)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
+	r.onOutage = ignoreClient(onOutage)
 
-	go func() {
-		for {
-			select {
-			case <-stopCh:
-				sub.Unsubscribe()
-				rpcClient.Close()
-				return
-			case txnHash := <-txHashCh:
-				go func() {
-					tx, isPending, err := utils.TransactionByHashWithRetry(ethClient, txnHash, 11)
-					if err != nil {
-						// Usually happens when eth.syncing is not false
-						// log.Printf("TransactionByHashWithRetry(%s) failed, error: %v", txnHash.Hex(), err)
-						return
-					}
-					// only care about pending transactions
-					if tx == nil || !isPending {
-						return
-					}
-					// Only care about transactions that interact with smart contracts
-					interactWithContract := tx.To() != nil && len(tx.Data()) > 0
-					if !interactWithContract {
-						return
-					}
+	return utils.Go(ctx, 1024, func(ctx context.Context, txCh chan<- pojo.TxData) error {
+		queue := newLookupQueue(options.QueueSize, options.Drop, onDrop)
+		wg := sync.WaitGroup{}
+		defer wg.Wait()
+		defer queue.close()
 
-					txData := pojo.TxData(pojo.NewRawTransaction(tx, fullNodeUrl))
-					// if both are empty, there is no filtering at all
-					if len(toWhiteList) == 0 && len(fromWhiteList) == 0 {
-						txCh <- txData
-					} else if len(toWhiteList) > 0 && toWhiteList[*tx.To()] {
-						// transactions sent to addresses in `toWhiteList`
-						txCh <- txData
-					} else if len(fromWhiteList) > 0 {
-						// or transactions sent from addresses in `toWhiteList`
-						msg, err := tx.AsMessage(types.LatestSignerForChainID(tx.ChainId()), nil)
-						if err == nil {
-							if fromWhiteList[msg.From()] {
-								txCh <- txData
-							}
-						}
-					}
-				}()
+		found := func(l hashLookup, tx *types.Transaction, pending bool) {
+			// only care about pending transactions
+			if !pending {
+				return
+			}
+			rawTx := pojo.NewLookedUpTransaction(tx, fullNodeUrl, l.notifiedAt, time.Since(l.notifiedAt))
+			if acceptPendingTx(rawTx, fromWhiteList, toWhiteList) {
+				utils.Send(ctx, txCh, pojo.TxData(rawTx))
 			}
 		}
-	}()
+		for i := 0; i < options.Workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for batch := queue.pop(options.BatchSize, options.Window); batch != nil; batch = queue.pop(options.BatchSize, options.Window) {
+					lookupBatch(ctx, batch, found)
+				}
+			}()
+		}
 
-	return nil
+		r.run(ctx, func(rpcClient *rpc.Client, p pendingTx) bool {
+			if p.tx != nil {
+				rawTx := pojo.NewRawTransaction(p.tx, fullNodeUrl)
+				if !acceptPendingTx(rawTx, fromWhiteList, toWhiteList) {
+					return true
+				}
+				return utils.Send(ctx, txCh, pojo.TxData(rawTx))
+			}
+			return queue.push(ctx, hashLookup{
+				rpcClient:  rpcClient,
+				hash:       p.hash,
+				notifiedAt: time.Now(),
+			})
+		})
+		return nil
+	}), nil
 }
 
-func SubscribeNewHead(fullNodeUrl string, stopCh <-chan struct{}) (<-chan *types.Header, error) {
-	ctx := context.Background()
-	ethClient, err := ethclient.DialContext(ctx, fullNodeUrl)
-	if err != nil {
-		return nil, err
+// Whether a pending transaction interacts with a contract and passes the
+// whitelists, if both are empty, there is no filtering at all. The sender
+// recovered is cached in tx.
+func acceptPendingTx(tx *pojo.RawTransaction, fromWhiteList map[common.Address]bool, toWhiteList map[common.Address]bool) bool {
+	// Only care about transactions that interact with smart contracts
+	interactWithContract := tx.To() != nil && len(tx.Data()) > 0
+	if !interactWithContract {
+		return false
 	}
+	if len(toWhiteList) == 0 && len(fromWhiteList) == 0 {
+		return true
+	}
+	// transactions sent to addresses in `toWhiteList`
+	if toWhiteList[*tx.To()] {
+		return true
+	}
+	// or transactions sent from addresses in `fromWhiteList`
+	if len(fromWhiteList) > 0 {
+		from := tx.From()
+		return from != nil && fromWhiteList[*from]
+	}
+	return false
+}
 
-	headerCh := make(chan *types.Header)
-
-	sub, err := ethClient.SubscribeNewHead(ctx, headerCh)
+// Subscribe new block headers from the fullnode until ctx is done.
+//
+// The subscription is restored automatically if the connection drops,
+// onOutage, if not nil, is called after every recovery.
+func SubscribeNewHead(ctx context.Context, fullNodeUrl string, onOutage func(Outage)) (*utils.Subscription[*types.Header], error) {
+	r, err := newResubscriber(ctx, fullNodeUrl, subscribeNewHead)
 	if err != nil {
 		return nil, err
 	}
-
-	go func() {
-		<-stopCh
-		sub.Unsubscribe()
-		ethClient.Close()
-		ctx.Done()
-		close(headerCh)
-	}()
-
-	return headerCh, nil
+	r.onOutage = ignoreClient(onOutage)
+
+	return utils.Go(ctx, 0, func(ctx context.Context, outCh chan<- *types.Header) error {
+		r.run(ctx, func(_ *rpc.Client, header *types.Header) bool {
+			return utils.Send(ctx, outCh, header)
+		})
+		return nil
+	}), nil
 }
 
-func SubscribeBlockHash(fullNodeUrl string, stopCh <-chan struct{}) (<-chan common.Hash, error) {
-	blockHashCh := make(chan common.Hash)
-	headerCh, err := SubscribeNewHead(fullNodeUrl, stopCh)
+// Subscribe new block hashes from the fullnode until ctx is done.
+func SubscribeBlockHash(ctx context.Context, fullNodeUrl string, onOutage func(Outage)) (*utils.Subscription[common.Hash], error) {
+	headerSub, err := SubscribeNewHead(ctx, fullNodeUrl, onOutage)
 	if err != nil {
 		return nil, err
 	}
 
-	go func() {
+	return utils.Go(ctx, 0, func(ctx context.Context, blockHashCh chan<- common.Hash) error {
+		defer headerSub.Close()
 		for {
 			select {
-			case <-stopCh:
-				close(blockHashCh)
-				return
-			case header := <-headerCh:
-				blockHashCh <- header.Hash()
+			case <-ctx.Done():
+				return nil
+			case header, ok := <-headerSub.C:
+				if !ok {
+					return headerSub.Wait()
+				}
+				if !utils.Send(ctx, blockHashCh, header.Hash()) {
+					return nil
+				}
 			}
 		}
-	}()
+	}), nil
+}
 
-	return blockHashCh, nil
+// At most this number of missed blocks are backfilled after a gap.
+const maxBackfill = 256
+
+// Subscribe new block headers from the fullnode until ctx is done.
+//
+// Blocks missed while the subscription was down are detected by the gap in
+// block numbers and fetched through HeaderByNumber(), these headers are
+// marked as backfilled and sent after the live header which revealed the gap.
+// A block which failed to fetch is fetched again after the next header.
+//
+// onOutage, see SubscribeNewHead().
+func SubscribeBlockHeaders(ctx context.Context, fullNodeUrl string, onOutage func(Outage)) (*utils.Subscription[*pojo.BlockHeader], error) {
+	r, err := newResubscriber(ctx, fullNodeUrl, subscribeNewHead)
+	if err != nil {
+		return nil, err
+	}
+	r.onOutage = ignoreClient(onOutage)
+
+	return utils.Go(ctx, 0, func(ctx context.Context, outCh chan<- *pojo.BlockHeader) error {
+		last := uint64(0)              // blocks up to last have been delivered, live or backfilled
+		above := make(map[uint64]bool) // live blocks delivered after a gap which is not filled yet
+		r.run(ctx, func(rpcClient *rpc.Client, header *types.Header) bool {
+			number := header.Number.Uint64()
+			live := &pojo.BlockHeader{Hash: header.Hash(), Number: number}
+			if !utils.Send(ctx, outCh, live) {
+				return false
+			}
+			if last == 0 || number <= last+1 {
+				if number > last {
+					last = number
+					for above[last+1] {
+						delete(above, last+1)
+						last++
+					}
+				}
+				return true
+			}
+
+			above[number] = true
+			if number-last-1 > maxBackfill {
+				log.Printf("Skipped backfilling blocks %d to %d", last+1, number-maxBackfill-1)
+				for n := range above {
+					if n < number-maxBackfill {
+						delete(above, n)
+					}
+				}
+				last = number - maxBackfill - 1
+			}
+			// last advances only after a block is delivered
+			ethClient := ethclient.NewClient(rpcClient)
+			for n := last + 1; n <= number; n = last + 1 {
+				if !above[n] {
+					missed, err := ethClient.HeaderByNumber(ctx, new(big.Int).SetUint64(n))
+					if err != nil {
+						if ctx.Err() == nil {
+							metrics.RPCErrors.WithLabelValues("eth_getBlockByNumber").Inc()
+							log.Printf("Failed to backfill block %d, error: %v", n, err)
+						}
+						return ctx.Err() == nil
+					}
+					backfilled := &pojo.BlockHeader{Hash: missed.Hash(), Number: n, Backfilled: true}
+					if !utils.Send(ctx, outCh, backfilled) {
+						return false
+					}
+				}
+				delete(above, n)
+				last = n
+			}
+			return true
+		})
+		return nil
+	}), nil
+}
+
+// Subscribe new blocks with their transaction hashes from the fullnode until
+// ctx is done, a block is fetched by eth_getBlockByHash after its header is
+// notified, blocks which failed to fetch are skipped.
+//
+// onOutage, see SubscribeNewHead().
+func SubscribeBlockTxHashes(ctx context.Context, fullNodeUrl string, onOutage func(Outage)) (*utils.Subscription[*pojo.BlockTxHashes], error) {
+	r, err := newResubscriber(ctx, fullNodeUrl, subscribeNewHead)
+	if err != nil {
+		return nil, err
+	}
+	r.onOutage = ignoreClient(onOutage)
+
+	return utils.Go(ctx, 0, func(ctx context.Context, outCh chan<- *pojo.BlockTxHashes) error {
+		r.run(ctx, func(rpcClient *rpc.Client, header *types.Header) bool {
+			notifiedAt := time.Now()
+			var block *struct {
+				Hash         common.Hash    `json:"hash"`
+				Number       hexutil.Uint64 `json:"number"`
+				Transactions []common.Hash  `json:"transactions"`
+			}
+			if err := rpcClient.CallContext(ctx, &block, "eth_getBlockByHash", header.Hash(), false); err != nil || block == nil {
+				if ctx.Err() != nil {
+					return false
+				}
+				metrics.RPCErrors.WithLabelValues("eth_getBlockByHash").Inc()
+				log.Printf("Failed to fetch block %s, error: %v", header.Hash().Hex(), err)
+				return true
+			}
+			return utils.Send(ctx, outCh, &pojo.BlockTxHashes{
+				Hash:       block.Hash,
+				Number:     uint64(block.Number),
+				TxHashes:   block.Transactions,
+				NotifiedAt: notifiedAt,
+			})
+		})
+		return nil
+	}), nil
+}
+
+// Same as pairInstance.GetReserves(), which panics instead of returning the error.
+func getReserves(opts *bind.CallOpts, pairInstance *pair.Pair) (*big.Int, *big.Int, uint32, error) {
+	var out []interface{}
+	raw := &pair.PairCallerRaw{Contract: &pairInstance.PairCaller}
+	if err := raw.Call(opts, &out, "getReserves"); err != nil {
+		return nil, nil, 0, err
+	}
+	return out[0].(*big.Int), out[1].(*big.Int), out[2].(uint32), nil
 }
 
 // Poll GetReserves() periodically from the fullnode.
-func PullPairReserves(fullNodeUrl string, pairs []common.Address, stopCh <-chan struct{}) (<-chan *pojo.PairReserve, error) {
-	ctx := context.Background()
+//
+// onError decides what to do when GetReserves() fails, nil means retry.
+func PullPairReserves(ctx context.Context, fullNodeUrl string, pairs []common.Address, onError ErrorHandler) (*utils.Subscription[*pojo.PairReserve], error) {
 	ethClient, err := ethclient.DialContext(ctx, fullNodeUrl)
 	if err != nil {
 		return nil, err
 	}
 
-	blockNumber, err := NewBlockNumberOnFullnode(fullNodeUrl, stopCh)
+	blockNumber, err := NewBlockNumberOnFullnode(ctx, fullNodeUrl)
 	if err != nil {
+		ethClient.Close()
 		return nil, err
 	}
 
@@ -174,156 +363,195 @@ func PullPairReserves(fullNodeUrl string, pairs []common.Address, stopCh <-chan
 	for _, pairAddress := range pairs {
 		pairInstance, err := pair.NewPair(pairAddress, ethClient)
 		if err != nil {
+			blockNumber.Close()
+			ethClient.Close()
 			return nil, err
 		}
 		pairInstances = append(pairInstances, pairInstance)
 	}
 
-	outCh := make(chan *pojo.PairReserve)
+	return utils.Go(ctx, 0, func(ctx context.Context, outCh chan<- *pojo.PairReserve) error {
+		defer ethClient.Close()
+		defer blockNumber.Close()
 
-	go func() {
+		opts := &bind.CallOpts{Context: ctx}
 		visited := make(map[uint64]bool)
-		for {
-			select {
-			case <-stopCh:
-				close(outCh)
-				return
-			default:
-				for i, pairInstance := range pairInstances {
-					ret, err := pairInstance.GetReserves(nil)
-					if err != nil {
-						panic(err)
-					}
+		for ctx.Err() == nil {
+			for i, pairInstance := range pairInstances {
+				var reserve0, reserve1 *big.Int
+				var blockTimestampLast uint32
+				ok, err := callWithPolicy(ctx, "eth_call", onError, func() (err error) {
+					reserve0, reserve1, blockTimestampLast, err = getReserves(opts, pairInstance)
+					return err
+				})
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
 
-					pairReserve := &pojo.PairReserve{
-						Pair:               pairs[i],
-						Reserve0:           pojo.NewBigInt(ret.Reserve0),
-						Reserve1:           pojo.NewBigInt(ret.Reserve1),
-						BlockTimestampLast: ret.BlockTimestampLast,
-						BlockNumber:        blockNumber.Get().Int64(),
-					}
-					hash := pairReserve.Hash()
-					if !visited[hash] {
-						outCh <- pairReserve
-						visited[hash] = true
+				pairReserve := &pojo.PairReserve{
+					Pair:               pairs[i],
+					Reserve0:           pojo.NewBigInt(reserve0),
+					Reserve1:           pojo.NewBigInt(reserve1),
+					BlockTimestampLast: blockTimestampLast,
+					BlockNumber:        blockNumber.Get().Int64(),
+				}
+				hash := pairReserve.Hash()
+				if !visited[hash] {
+					if !utils.Send(ctx, outCh, pairReserve) {
+						return nil
 					}
+					visited[hash] = true
+				} else {
+					metrics.DedupHits.WithLabelValues("PullPairReserves").Inc()
 				}
 			}
 		}
-	}()
-
-	return outCh, nil
+		return nil
+	}), nil
 }
 
 // BulkReader
-func PullPairReservesBulk(fullNodeUrl string, pairs []common.Address, stopCh <-chan struct{}) (<-chan *pojo.PairReserve, error) {
-	ctx := context.Background()
+//
+// onError, see PullPairReserves().
+func PullPairReservesBulk(ctx context.Context, fullNodeUrl string, pairs []common.Address, onError ErrorHandler) (*utils.Subscription[*pojo.PairReserve], error) {
 	ethClient, err := ethclient.DialContext(ctx, fullNodeUrl)
 	if err != nil {
 		return nil, err
 	}
 
-	blockNumber, err := NewBlockNumberOnFullnode(fullNodeUrl, stopCh)
+	blockNumber, err := NewBlockNumberOnFullnode(ctx, fullNodeUrl)
 	if err != nil {
+		ethClient.Close()
 		return nil, err
 	}
 
 	router := common.HexToAddress("0x45974B68d81Be55E71F7ACD5c1378a9d52CF02Be")
 	bulkReader, err := abi.NewBulkReader(router, ethClient)
 	if err != nil {
+		blockNumber.Close()
+		ethClient.Close()
 		return nil, err
 	}
 
-	outCh := make(chan *pojo.PairReserve)
+	return utils.Go(ctx, 0, func(ctx context.Context, outCh chan<- *pojo.PairReserve) error {
+		defer ethClient.Close()
+		defer blockNumber.Close()
 
-	go func() {
+		opts := &bind.CallOpts{Context: ctx}
 		visited := make(map[uint64]bool)
-		for {
-			select {
-			case <-stopCh:
-				close(outCh)
-				return
-			default:
-				arr, err := bulkReader.GetReservesForBenchmark(nil, pairs)
-				if err != nil {
-					panic(err)
+		for ctx.Err() == nil {
+			var arr [][3]*big.Int
+			ok, err := callWithPolicy(ctx, "eth_call", onError, func() (err error) {
+				arr, err = bulkReader.GetReservesForBenchmark(opts, pairs)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			for i := 0; i < len(pairs); i++ {
+				pairReserve := &pojo.PairReserve{
+					Pair:               pairs[i],
+					Reserve0:           pojo.NewBigInt(arr[i][0]),
+					Reserve1:           pojo.NewBigInt(arr[i][1]),
+					BlockTimestampLast: uint32(arr[i][2].Int64()),
+					BlockNumber:        blockNumber.Get().Int64(),
 				}
-				for i := 0; i < len(pairs); i++ {
-					pairReserve := &pojo.PairReserve{
-						Pair:               pairs[i],
-						Reserve0:           pojo.NewBigInt(arr[i][0]),
-						Reserve1:           pojo.NewBigInt(arr[i][1]),
-						BlockTimestampLast: uint32(arr[i][2].Int64()),
-						BlockNumber:        blockNumber.Get().Int64(),
-					}
-					hash := pairReserve.Hash()
-					if !visited[hash] {
-						outCh <- pairReserve
-						visited[hash] = true
+				hash := pairReserve.Hash()
+				if !visited[hash] {
+					if !utils.Send(ctx, outCh, pairReserve) {
+						return nil
 					}
+					visited[hash] = true
+				} else {
+					metrics.DedupHits.WithLabelValues("PullPairReservesBulk").Inc()
 				}
 			}
 		}
-	}()
-
-	return outCh, nil
+		return nil
+	}), nil
 }
 
 // BulkReader + header
-func PullPairReservesBulkHeader(fullNodeUrl string, pairs []common.Address, stopCh <-chan struct{}) (<-chan *pojo.PairReserve, error) {
-	ctx := context.Background()
+//
+// onOutage, see SubscribeNewHead(), onError, see PullPairReserves().
+func PullPairReservesBulkHeader(ctx context.Context, fullNodeUrl string, pairs []common.Address, onOutage func(Outage), onError ErrorHandler) (*utils.Subscription[*pojo.PairReserve], error) {
 	ethClient, err := ethclient.DialContext(ctx, fullNodeUrl)
 	if err != nil {
 		return nil, err
 	}
 
-	blockNumber, err := NewBlockNumberOnFullnode(fullNodeUrl, stopCh)
+	blockNumber, err := NewBlockNumberOnFullnode(ctx, fullNodeUrl)
 	if err != nil {
+		ethClient.Close()
 		return nil, err
 	}
 
-	headerCh, err := SubscribeNewHead(fullNodeUrl, stopCh)
+	router := common.HexToAddress("0x45974B68d81Be55E71F7ACD5c1378a9d52CF02Be")
+	bulkReader, err := abi.NewBulkReader(router, ethClient)
 	if err != nil {
+		blockNumber.Close()
+		ethClient.Close()
 		return nil, err
 	}
 
-	router := common.HexToAddress("0x45974B68d81Be55E71F7ACD5c1378a9d52CF02Be")
-	bulkReader, err := abi.NewBulkReader(router, ethClient)
+	headerSub, err := SubscribeNewHead(ctx, fullNodeUrl, onOutage)
 	if err != nil {
+		blockNumber.Close()
+		ethClient.Close()
 		return nil, err
 	}
 
-	outCh := make(chan *pojo.PairReserve)
+	return utils.Go(ctx, 0, func(ctx context.Context, outCh chan<- *pojo.PairReserve) error {
+		defer ethClient.Close()
+		defer blockNumber.Close()
+		defer headerSub.Close()
 
-	go func() {
+		opts := &bind.CallOpts{Context: ctx}
 		visited := make(map[uint64]bool)
 		for {
 			select {
-			case <-stopCh:
-				close(outCh)
-				return
-			case <-headerCh:
-				arr, err := bulkReader.GetReservesForBenchmark(nil, pairs)
-				if err != nil {
-					panic(err)
+			case <-ctx.Done():
+				return nil
+			case _, ok := <-headerSub.C:
+				if !ok {
+					return headerSub.Wait()
 				}
-				for i := 0; i < len(pairs); i++ {
-					pairReserve := &pojo.PairReserve{
-						Pair:               pairs[i],
-						Reserve0:           pojo.NewBigInt(arr[i][0]),
-						Reserve1:           pojo.NewBigInt(arr[i][1]),
-						BlockTimestampLast: uint32(arr[i][2].Int64()),
-						BlockNumber:        blockNumber.Get().Int64(),
-					}
-					hash := pairReserve.Hash()
-					if !visited[hash] {
-						outCh <- pairReserve
-						visited[hash] = true
+			}
+			var arr [][3]*big.Int
+			ok, err := callWithPolicy(ctx, "eth_call", onError, func() (err error) {
+				arr, err = bulkReader.GetReservesForBenchmark(opts, pairs)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			for i := 0; i < len(pairs); i++ {
+				pairReserve := &pojo.PairReserve{
+					Pair:               pairs[i],
+					Reserve0:           pojo.NewBigInt(arr[i][0]),
+					Reserve1:           pojo.NewBigInt(arr[i][1]),
+					BlockTimestampLast: uint32(arr[i][2].Int64()),
+					BlockNumber:        blockNumber.Get().Int64(),
+				}
+				hash := pairReserve.Hash()
+				if !visited[hash] {
+					if !utils.Send(ctx, outCh, pairReserve) {
+						return nil
 					}
+					visited[hash] = true
+				} else {
+					metrics.DedupHits.WithLabelValues("PullPairReservesBulkHeader").Inc()
 				}
 			}
 		}
-	}()
-
-	return outCh, nil
+	}), nil
 }
@@ -0,0 +1,192 @@
+package clients
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/testutil"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testPair1 = common.HexToAddress("0x01")
+	testPair2 = common.HexToAddress("0x02")
+)
+
+// A fake fullnode which mines a block every 10ms for 10 seconds, block
+// 100+i has a transaction to testPair1 and reserves (i, 2i) of both pairs.
+func newTestNode(t *testing.T) *testutil.Node {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+
+	timeline := testutil.Timeline{Start: 100, Interval: 10 * time.Millisecond}
+	for i := 0; i < 1000; i++ {
+		reserve := testutil.Reserve{
+			Reserve0:           big.NewInt(int64(i)),
+			Reserve1:           big.NewInt(int64(2 * i)),
+			BlockTimestampLast: uint32(i),
+		}
+		timeline.Blocks = append(timeline.Blocks, testutil.Block{
+			Txs:      []*types.Transaction{testutil.NewTx(key, uint64(i), testPair1, []byte{1, 2, 3, 4})},
+			Reserves: map[common.Address]testutil.Reserve{testPair1: reserve, testPair2: reserve},
+		})
+	}
+	return testutil.NewNode(t, timeline)
+}
+
+func TestSubscribePendingTx(t *testing.T) {
+	alice, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	bob, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	toAlice := testutil.NewTx(alice, 0, testPair1, []byte{1, 2, 3, 4})
+	toBob := testutil.NewTx(bob, 0, testPair2, []byte{1, 2, 3, 4})
+	transfer := testutil.NewTx(alice, 1, testPair1, nil) // doesn't interact with a contract
+
+	node := testutil.NewNode(t, testutil.Timeline{Start: 100, Blocks: []testutil.Block{
+		{},
+		{Txs: []*types.Transaction{transfer, toAlice, toBob}},
+	}})
+	hashesOnly := false
+	receive := func(fromWhiteList map[common.Address]bool, toWhiteList map[common.Address]bool) []common.Hash {
+		sub, err := SubscribePendingTx(context.Background(), node.URL(), fromWhiteList, toWhiteList, LookupOptions{}, nil, nil)
+		assert.NoError(t, err)
+		defer sub.Close()
+
+		hashes := make([]common.Hash, 0)
+		timeout := time.After(200 * time.Millisecond)
+		for {
+			select {
+			case tx := <-sub.C:
+				assert.Equal(t, node.URL(), tx.Source())
+				// only transactions looked up by hashes have a lookup delay
+				notifiedAt, _ := tx.(*pojo.RawTransaction).Lookup()
+				assert.Equal(t, hashesOnly, !notifiedAt.IsZero())
+				hashes = append(hashes, tx.Hash())
+			case <-timeout:
+				return hashes
+			}
+		}
+	}
+
+	for _, hashesOnly = range []bool{false, true} {
+		node.SetHashesOnly(hashesOnly)
+		assert.ElementsMatch(t, []common.Hash{toAlice.Hash(), toBob.Hash()}, receive(nil, nil))
+		assert.Equal(t, []common.Hash{toBob.Hash()}, receive(nil, map[common.Address]bool{testPair2: true}))
+		assert.Equal(t, []common.Hash{toAlice.Hash()}, receive(map[common.Address]bool{crypto.PubkeyToAddress(alice.PublicKey): true}, nil))
+	}
+}
+
+// Read n reserves of testPair1, they are deduplicated by reserves and block
+// number and never go back in this timeline.
+func readReserves(t *testing.T, sub interface{ Close() error }, ch <-chan *pojo.PairReserve, n int) []*pojo.PairReserve {
+	reserves := make([]*pojo.PairReserve, 0, n)
+	visited := make(map[uint64]bool)
+	timeout := time.After(5 * time.Second)
+	for len(reserves) < n {
+		select {
+		case reserve := <-ch:
+			if reserve.Pair != testPair1 {
+				continue
+			}
+			assert.Equal(t, 0, new(big.Int).Mul(reserve.Reserve0.Int, big.NewInt(2)).Cmp(reserve.Reserve1.Int))
+			if len(reserves) > 0 {
+				assert.GreaterOrEqual(t, reserve.Reserve0.Int64(), reserves[len(reserves)-1].Reserve0.Int64())
+			}
+			assert.False(t, visited[reserve.Hash()])
+			visited[reserve.Hash()] = true
+			reserves = append(reserves, reserve)
+		case <-timeout:
+			assert.FailNow(t, "not enough reserves", "%d received", len(reserves))
+		}
+	}
+	assert.NoError(t, sub.Close())
+	return reserves
+}
+
+func TestPullPairReserves(t *testing.T) {
+	node := newTestNode(t)
+	pairs := []common.Address{testPair1, testPair2}
+	ctx := context.Background()
+
+	sub, err := PullPairReserves(ctx, node.URL(), pairs, nil)
+	assert.NoError(t, err)
+	readReserves(t, sub, sub.C, 5)
+
+	sub, err = PullPairReservesBulk(ctx, node.URL(), pairs, nil)
+	assert.NoError(t, err)
+	readReserves(t, sub, sub.C, 5)
+
+	sub, err = PullPairReservesBulkHeader(ctx, node.URL(), pairs, nil, nil)
+	assert.NoError(t, err)
+	for _, reserve := range readReserves(t, sub, sub.C, 5) {
+		assert.Greater(t, reserve.BlockNumber, int64(100))
+	}
+}
+
+func TestPullPairReservesErrorPolicy(t *testing.T) {
+	node := newTestNode(t)
+	pairs := []common.Address{testPair1}
+	ctx := context.Background()
+
+	node.Fail("eth_call", 3)
+	reported := 0
+	sub, err := PullPairReserves(ctx, node.URL(), pairs, func(method string, err error) ErrorPolicy {
+		assert.Equal(t, "eth_call", method)
+		assert.Contains(t, err.Error(), testutil.ErrInjected.Error())
+		reported++
+		return ErrorPolicyRetry
+	})
+	assert.NoError(t, err)
+	readReserves(t, sub, sub.C, 2)
+	assert.Equal(t, 3, reported)
+
+	node.Fail("eth_call", 1)
+	sub, err = PullPairReservesBulk(ctx, node.URL(), pairs, FixedPolicy(ErrorPolicyAbort))
+	assert.NoError(t, err)
+	_, ok := <-sub.C
+	assert.False(t, ok)
+	assert.ErrorContains(t, sub.Wait(), testutil.ErrInjected.Error())
+}
+
+func TestSubscribeBlockTxHashes(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	txs := []*types.Transaction{testutil.NewTx(key, 0, testPair1, nil), testutil.NewTx(key, 1, testPair2, nil)}
+	node := testutil.NewNode(t, testutil.Timeline{Start: 100, Blocks: []testutil.Block{{}, {Txs: txs}, {}, {Txs: txs[:1]}}})
+
+	sub, err := SubscribeBlockTxHashes(context.Background(), node.URL(), nil)
+	assert.NoError(t, err)
+	defer sub.Close()
+	receive := func() *pojo.BlockTxHashes {
+		select {
+		case block := <-sub.C:
+			return block
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "no block")
+			return nil
+		}
+	}
+
+	node.Mine()
+	block := receive()
+	assert.Equal(t, uint64(101), block.Number)
+	assert.Equal(t, node.Header(101).Hash(), block.Hash)
+	assert.Equal(t, []common.Hash{txs[0].Hash(), txs[1].Hash()}, block.TxHashes)
+	assert.WithinDuration(t, time.Now(), block.NotifiedAt, time.Second)
+
+	// skipped if the block failed to fetch
+	node.Fail("eth_getBlockByHash", 1)
+	node.Mine()
+	time.Sleep(50 * time.Millisecond)
+	node.Mine()
+	block = receive()
+	assert.Equal(t, uint64(103), block.Number)
+	assert.Equal(t, []common.Hash{txs[0].Hash()}, block.TxHashes)
+}
@@ -0,0 +1,105 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// How to read pair reserves from a fullnode.
+type ReserveMode string
+
+const (
+	ReserveModePoll       ReserveMode = "poll"        // PullPairReserves()
+	ReserveModeBulk       ReserveMode = "bulk"        // PullPairReservesBulk()
+	ReserveModeBulkHeader ReserveMode = "bulk_header" // PullPairReservesBulkHeader()
+)
+
+func hashEvent(hash common.Hash) (string, interface{}, bool) {
+	return hash.Hex(), map[string]common.Hash{"hash": hash}, true
+}
+
+func pairReserveEvent(pairReserve *pojo.PairReserve) (string, interface{}, bool) {
+	return strconv.FormatUint(pairReserve.Hash(), 10), pairReserve, true
+}
+
+func blockHeaderEvent(header *pojo.BlockHeader) (string, interface{}, bool) {
+	return header.Hash.Hex(), header, true
+}
+
+func txDataEvent(tx pojo.TxData) (string, interface{}, bool) {
+	return tx.Hash().Hex(), tx, true
+}
+
+// Pending transaction hashes from SubscribePendingTxHash().
+func NewFullnodeTxHashSource(name string, fullNodeUrl string) Source {
+	return &channelSource[common.Hash]{
+		name: name,
+		kind: KindTx,
+		subscribe: func(ctx context.Context, hooks hooks) (*utils.Subscription[common.Hash], error) {
+			return SubscribePendingTxHash(ctx, fullNodeUrl, hooks.onOutage)
+		},
+		toEvent: hashEvent,
+	}
+}
+
+// Pending transactions from SubscribePendingTx(), hashes dropped by the
+// lookup queue are counted in SourceStats.Dropped.
+func NewFullnodeTxSource(name string, fullNodeUrl string, fromWhiteList map[common.Address]bool, toWhiteList map[common.Address]bool, options LookupOptions) Source {
+	return &channelSource[pojo.TxData]{
+		name: name,
+		kind: KindTx,
+		subscribe: func(ctx context.Context, hooks hooks) (*utils.Subscription[pojo.TxData], error) {
+			return SubscribePendingTx(ctx, fullNodeUrl, fromWhiteList, toWhiteList, options, hooks.onOutage, func(common.Hash) { hooks.onDrop() })
+		},
+		toEvent: txDataEvent,
+	}
+}
+
+// Block headers from SubscribeBlockHeaders(), including backfilled ones.
+func NewFullnodeBlockSource(name string, fullNodeUrl string) Source {
+	return &channelSource[*pojo.BlockHeader]{
+		name: name,
+		kind: KindBlock,
+		subscribe: func(ctx context.Context, hooks hooks) (*utils.Subscription[*pojo.BlockHeader], error) {
+			return SubscribeBlockHeaders(ctx, fullNodeUrl, hooks.onOutage)
+		},
+		toEvent: blockHeaderEvent,
+	}
+}
+
+// Pair reserves polled from a fullnode.
+//
+// `policy`, what to do after eth_call failed, retry if empty, every failure
+// is also written to the output file along with the error count.
+func NewFullnodeReserveSource(name string, fullNodeUrl string, pairs []common.Address, mode ReserveMode, policy ErrorPolicy) (Source, error) {
+	var pull func(context.Context, hooks) (*utils.Subscription[*pojo.PairReserve], error)
+	switch mode {
+	case ReserveModePoll:
+		pull = func(ctx context.Context, hooks hooks) (*utils.Subscription[*pojo.PairReserve], error) {
+			return PullPairReserves(ctx, fullNodeUrl, pairs, hooks.onError)
+		}
+	case ReserveModeBulk:
+		pull = func(ctx context.Context, hooks hooks) (*utils.Subscription[*pojo.PairReserve], error) {
+			return PullPairReservesBulk(ctx, fullNodeUrl, pairs, hooks.onError)
+		}
+	case ReserveModeBulkHeader:
+		pull = func(ctx context.Context, hooks hooks) (*utils.Subscription[*pojo.PairReserve], error) {
+			return PullPairReservesBulkHeader(ctx, fullNodeUrl, pairs, hooks.onOutage, hooks.onError)
+		}
+	default:
+		return nil, fmt.Errorf("invalid reserve mode: %s", mode)
+	}
+
+	return &channelSource[*pojo.PairReserve]{
+		name:      name,
+		kind:      KindReserve,
+		subscribe: pull,
+		policy:    policy,
+		toEvent:   pairReserveEvent,
+	}, nil
+}
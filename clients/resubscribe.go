@@ -0,0 +1,123 @@
+package clients
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// An outage of a websocket feed, from the moment the subscription failed to
+// the moment it was restored, records in between may be missing.
+type Outage struct {
+	Start time.Time
+	End   time.Time
+	Err   error // why the subscription failed
+}
+
+// Exponential backoff between reconnections.
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+var DefaultBackoff = Backoff{Min: 100 * time.Millisecond, Max: 30 * time.Second}
+
+// Keeps a websocket subscription of a fullnode alive, it dials again and
+// resubscribes with exponential backoff whenever the subscription fails.
+type resubscriber[T any] struct {
+	fullNodeUrl string
+	backoff     Backoff
+	subscribe   func(ctx context.Context, rpcClient *rpc.Client, ch chan<- T) (ethereum.Subscription, error)
+	// Called after the subscription is restored, optional.
+	onOutage func(rpcClient *rpc.Client, outage Outage)
+
+	ch        chan T // shared by all subscriptions
+	rpcClient *rpc.Client
+	sub       ethereum.Subscription
+}
+
+// Dial and subscribe for the first time, errors are returned instead of retried.
+func newResubscriber[T any](ctx context.Context, fullNodeUrl string, subscribe func(ctx context.Context, rpcClient *rpc.Client, ch chan<- T) (ethereum.Subscription, error)) (*resubscriber[T], error) {
+	r := &resubscriber[T]{
+		fullNodeUrl: fullNodeUrl,
+		backoff:     DefaultBackoff,
+		subscribe:   subscribe,
+		ch:          make(chan T, 1024),
+	}
+	if err := r.connect(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *resubscriber[T]) connect(ctx context.Context) error {
+	rpcClient, err := rpc.DialContext(ctx, r.fullNodeUrl)
+	if err != nil {
+		return err
+	}
+	sub, err := r.subscribe(ctx, rpcClient, r.ch)
+	if err != nil {
+		rpcClient.Close()
+		return err
+	}
+	r.rpcClient = rpcClient
+	r.sub = sub
+	return nil
+}
+
+func (r *resubscriber[T]) close() {
+	if r.sub != nil {
+		r.sub.Unsubscribe()
+		r.sub = nil
+	}
+	if r.rpcClient != nil {
+		r.rpcClient.Close()
+		r.rpcClient = nil
+	}
+}
+
+// Reconnect until it succeeds or ctx is done, returns false if ctx is done.
+func (r *resubscriber[T]) reconnect(ctx context.Context) bool {
+	delay := r.backoff.Min
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		if err := r.connect(ctx); err == nil {
+			return true
+		}
+		delay *= 2
+		if delay > r.backoff.Max {
+			delay = r.backoff.Max
+		}
+	}
+}
+
+// Run calls handle on every element with the current connection, until ctx
+// is done or handle returns false.
+func (r *resubscriber[T]) run(ctx context.Context, handle func(rpcClient *rpc.Client, x T) bool) {
+	defer r.close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-r.sub.Err():
+			start := time.Now()
+			r.close()
+			if !r.reconnect(ctx) {
+				return
+			}
+			if r.onOutage != nil {
+				r.onOutage(r.rpcClient, Outage{Start: start, End: time.Now(), Err: err})
+			}
+		case x := <-r.ch:
+			if !handle(r.rpcClient, x) {
+				return
+			}
+		}
+	}
+}
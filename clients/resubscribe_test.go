@@ -0,0 +1,147 @@
+package clients
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribePendingTxHashResubscribe(t *testing.T) {
+	node := newTestNode(t)
+
+	outageCh := make(chan Outage, 1)
+	sub, err := SubscribePendingTxHash(context.Background(), node.URL(), func(outage Outage) {
+		outageCh <- outage
+	})
+	assert.NoError(t, err)
+	defer sub.Close()
+
+	<-sub.C
+	node.SetDown(true)
+	node.Drop()
+	time.Sleep(300 * time.Millisecond) // a few failed reconnections
+	node.SetDown(false)
+
+	timeout := time.After(5 * time.Second)
+	for resubscribed := false; !resubscribed; {
+		select {
+		case <-sub.C: // keep reading, otherwise the subscription blocks
+		case outage := <-outageCh:
+			assert.GreaterOrEqual(t, outage.End.Sub(outage.Start), 300*time.Millisecond)
+			assert.Error(t, outage.Err)
+			resubscribed = true
+		case <-timeout:
+			assert.FailNow(t, "not resubscribed")
+		}
+	}
+	// hashes keep coming after the outage
+	for i := 0; i < 3; i++ {
+		select {
+		case <-sub.C:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "no hashes after the outage")
+		}
+	}
+	assert.NoError(t, sub.Close())
+}
+
+func TestChannelSourceOutage(t *testing.T) {
+	node := newTestNode(t)
+	source := NewFullnodeBlockSource("fullnode-block", node.URL())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eventCh, err := source.Start(ctx)
+	assert.NoError(t, err)
+
+	<-eventCh
+	node.SetDown(true)
+	node.Drop()
+	time.Sleep(300 * time.Millisecond) // failed attempts are not reconnections
+	node.SetDown(false)
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-eventCh:
+			if _, ok := event.Data.(Outage); ok {
+				assert.Equal(t, "", event.Key)
+				assert.Equal(t, uint64(1), source.Stats().Reconnects)
+				assert.Equal(t, 1.0, promtestutil.ToFloat64(metrics.Reconnects.WithLabelValues("fullnode-block")))
+				return
+			}
+		case <-timeout:
+			assert.FailNow(t, "no outage event")
+		}
+	}
+}
+
+func TestBlockNumberOnFullnodeResubscribe(t *testing.T) {
+	node := newTestNode(t)
+
+	blockNumber, err := NewBlockNumberOnFullnode(context.Background(), node.URL())
+	assert.NoError(t, err)
+	defer blockNumber.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	number1 := blockNumber.Get()
+	node.Drop()
+	time.Sleep(500 * time.Millisecond)
+	number2 := blockNumber.Get()
+
+	// the block number doesn't freeze after the connection drops
+	assert.Greater(t, number2.Uint64(), number1.Uint64()+5)
+	assert.NoError(t, blockNumber.Close())
+}
+
+func TestSubscribeBlockHeadersBackfill(t *testing.T) {
+	// the first backfill fails once, the gap is filled after the next header
+	for _, failures := range []int{0, 1} {
+		node := newTestNode(t)
+
+		sub, err := SubscribeBlockHeaders(context.Background(), node.URL(), nil)
+		assert.NoError(t, err)
+
+		first := <-sub.C
+		assert.False(t, first.Backfilled)
+		node.SetDown(true)
+		node.Drop()
+		time.Sleep(300 * time.Millisecond) // about 30 blocks
+		node.Fail("eth_getBlockByNumber", failures)
+		node.SetDown(false)
+
+		// every block after the first one arrives once, live or backfilled
+		seen := map[uint64]int{first.Number: 1}
+		last := first.Number
+		contiguous := func() bool {
+			for number := first.Number; number <= last; number++ {
+				if seen[number] == 0 {
+					return false
+				}
+			}
+			return true
+		}
+		backfilled := 0
+		timeout := time.After(5 * time.Second)
+		for backfilled < 20 || !contiguous() {
+			select {
+			case header := <-sub.C:
+				if header.Backfilled {
+					backfilled++
+					assert.Equal(t, header.Hash, node.Header(header.Number).Hash())
+				}
+				seen[header.Number]++
+				assert.Equal(t, 1, seen[header.Number], "block %d is duplicated", header.Number)
+				if header.Number > last {
+					last = header.Number
+				}
+			case <-timeout:
+				assert.FailNow(t, "not backfilled", "%d blocks backfilled after %d failures", backfilled, failures)
+			}
+		}
+		assert.NoError(t, sub.Close())
+	}
+}
@@ -0,0 +1,58 @@
+package clients
+
+import (
+	"sync"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+)
+
+// RecoverSenders recovers senders of transaction events with `workers`
+// goroutines, so that later stages calling pojo.TxData.From(), e.g.,
+// FilterTx() with From, find them cached. Events keep their order.
+//
+// Only worth it for high-volume streams, each recovery takes about as long
+// as a signature verification, see the benchmarks in pojo.
+func RecoverSenders(eventCh <-chan Event, workers int) <-chan Event {
+	if workers < 1 {
+		workers = 1
+	}
+	type job struct {
+		event Event
+		done  chan Event
+	}
+	jobCh := make(chan job, workers)
+	// results in order, bounds events in flight
+	orderCh := make(chan chan Event, workers*4)
+	outCh := make(chan Event, 1024)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobCh {
+				if tx, ok := j.event.Data.(pojo.TxData); ok {
+					tx.From()
+				}
+				j.done <- j.event
+			}
+		}()
+	}
+	go func() {
+		defer close(orderCh)
+		defer close(jobCh)
+		for event := range eventCh {
+			done := make(chan Event, 1)
+			orderCh <- done
+			jobCh <- job{event, done}
+		}
+	}()
+	go func() {
+		defer close(outCh)
+		defer wg.Wait()
+		for done := range orderCh {
+			outCh <- <-done
+		}
+	}()
+	return outCh
+}
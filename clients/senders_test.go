@@ -0,0 +1,38 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/testutil"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverSenders(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	eventCh := make(chan Event)
+	go func() {
+		defer close(eventCh)
+		for i := 0; i < 100; i++ {
+			tx := testutil.NewTx(key, uint64(i), common.HexToAddress("0x01"), nil)
+			eventCh <- Event{Key: tx.Hash().Hex(), Data: pojo.NewRawTransaction(tx, "test")}
+		}
+		eventCh <- Event{Key: "outage", Data: Outage{}}
+	}()
+
+	n := 0
+	for event := range RecoverSenders(eventCh, 8) {
+		if n == 100 {
+			assert.Equal(t, "outage", event.Key)
+		} else {
+			tx := event.Data.(pojo.TxData)
+			assert.Equal(t, uint64(n), tx.Nonce())
+			assert.Equal(t, &sender, tx.From())
+		}
+		n++
+	}
+	assert.Equal(t, 101, n)
+}
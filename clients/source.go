@@ -0,0 +1,269 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+)
+
+// Kind of the stream a source provides.
+type Kind string
+
+const (
+	KindTx      Kind = "tx"
+	KindBlock   Kind = "block"
+	KindReserve Kind = "reserve"
+)
+
+// A record received from a source.
+type Event struct {
+	Source string
+	Kind   Kind
+	// Identity of the record, used to join the same record across sources,
+	// it is the lower-case hex hash for blocks and transactions, and
+	// PairReserve.Hash() in decimal for pair reserves.
+	Key        string
+	ReceivedAt time.Time   // taken as soon as the record arrives
+	Data       interface{} // the record to write to the output file, an Outage or a FeedError
+	// The record was fetched to fill a gap rather than pushed, it counts for
+	// coverage but not for latency.
+	Backfilled bool
+	// Time taken to look up the record after it was notified, ReceivedAt is
+	// the notification time.
+	LookupDelay time.Duration
+	// Fields added to the record, e.g., the method called, see Decode().
+	Extra map[string]interface{}
+}
+
+// Implemented by records which might be backfilled, e.g., pojo.BlockHeader.
+type backfillable interface {
+	IsBackfilled() bool
+}
+
+// Implemented by records which might be looked up after notified, e.g.,
+// pojo.RawTransaction.
+type lookedUp interface {
+	Lookup() (time.Time, time.Duration)
+}
+
+type SourceStats struct {
+	Received   uint64 `json:"received"`
+	Errors     uint64 `json:"errors"`
+	Reconnects uint64 `json:"reconnects"`
+	Dropped    uint64 `json:"dropped"`
+}
+
+// A feed of transactions, blocks or pair reserves.
+type Source interface {
+	Name() string
+	Kind() Kind
+	// Start subscribing, the returned channel is closed after ctx is done.
+	Start(ctx context.Context) (<-chan Event, error)
+	Stats() SourceStats
+}
+
+// Counters shared by all sources.
+type sourceCounters struct {
+	received   uint64
+	errors     uint64
+	reconnects uint64
+	dropped    uint64
+}
+
+func (c *sourceCounters) Stats() SourceStats {
+	return SourceStats{
+		Received:   atomic.LoadUint64(&c.received),
+		Errors:     atomic.LoadUint64(&c.errors),
+		Reconnects: atomic.LoadUint64(&c.reconnects),
+		Dropped:    atomic.LoadUint64(&c.dropped),
+	}
+}
+
+// Callbacks of the subscription functions in this package, in which a source
+// turns outages and errors into events.
+type hooks struct {
+	onOutage func(Outage) // called after the subscription recovers from a dropped connection
+	onError  ErrorHandler // called after a request of a polling loop failed
+	onDrop   func()       // called after a record was dropped because a queue was full
+}
+
+// Adapts one of the subscription functions in this package to the Source
+// interface.
+type channelSource[T any] struct {
+	sourceCounters
+	name string
+	kind Kind
+	// Subscribe until ctx is done.
+	subscribe func(ctx context.Context, hooks hooks) (*utils.Subscription[T], error)
+	// What to do after a request of a polling loop failed, retry if empty.
+	policy ErrorPolicy
+	// Extract the key and the output record, return false to drop x.
+	toEvent func(x T) (string, interface{}, bool)
+}
+
+func (s *channelSource[T]) Name() string {
+	return s.name
+}
+
+func (s *channelSource[T]) Kind() Kind {
+	return s.kind
+}
+
+func (s *channelSource[T]) Start(ctx context.Context) (<-chan Event, error) {
+	outCh := make(chan Event, 1024)
+	// called only after the subscription is restored
+	onOutage := func(outage Outage) {
+		atomic.AddUint64(&s.reconnects, 1)
+		metrics.Reconnects.WithLabelValues(s.name).Inc()
+		log.Printf("%s: resubscribed after %v, error: %v", s.name, outage.End.Sub(outage.Start), outage.Err)
+		// called by the subscription, which is closed before outCh
+		utils.Send(ctx, outCh, Event{
+			Source:     s.name,
+			Kind:       s.kind,
+			ReceivedAt: outage.End,
+			Data:       outage,
+		})
+	}
+	onError := func(method string, err error) ErrorPolicy {
+		count := atomic.AddUint64(&s.errors, 1)
+		log.Printf("%s: %s failed, error: %v", s.name, method, err)
+		utils.Send(ctx, outCh, Event{
+			Source:     s.name,
+			Kind:       s.kind,
+			ReceivedAt: time.Now(),
+			Data:       FeedError{Method: method, Err: err, Count: count},
+		})
+		if s.policy == "" {
+			return ErrorPolicyRetry
+		}
+		return s.policy
+	}
+	onDrop := func() {
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	sub, err := s.subscribe(ctx, hooks{onOutage: onOutage, onError: onError, onDrop: onDrop})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(outCh)
+		defer func() {
+			// release connections before closing outCh
+			if err := sub.Close(); err != nil {
+				log.Printf("%s: %v", s.name, err)
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case x, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				receivedAt := time.Now()
+				key, data, ok := s.toEvent(x)
+				if !ok {
+					atomic.AddUint64(&s.errors, 1)
+					continue
+				}
+				atomic.AddUint64(&s.received, 1)
+				metrics.MessagesReceived.WithLabelValues(s.name).Inc()
+				event := Event{
+					Source:     s.name,
+					Kind:       s.kind,
+					Key:        key,
+					ReceivedAt: receivedAt,
+					Data:       data,
+				}
+				if b, ok := data.(backfillable); ok {
+					event.Backfilled = b.IsBackfilled()
+				}
+				if l, ok := data.(lookedUp); ok {
+					if notifiedAt, delay := l.Lookup(); !notifiedAt.IsZero() {
+						event.ReceivedAt = notifiedAt
+						event.LookupDelay = delay
+					}
+				}
+				if !utils.Send(ctx, outCh, event) {
+					return
+				}
+			}
+		}
+	}()
+
+	return outCh, nil
+}
+
+// Records converts events to JSON records for utils.Run(), with received_at
+// taken from the clock, lookup_delay in milliseconds if the record was
+// looked up, and Extra fields of the event. observe, if not nil, is called on every event except
+// outages and errors, which are written as utils.Outage records and
+// {"method", "error", "errors"} records respectively.
+func Records(eventCh <-chan Event, clock *utils.Clock, observe func(Event)) <-chan map[string]interface{} {
+	outCh := make(chan map[string]interface{}, 1024)
+	go func() {
+		defer close(outCh)
+		for event := range eventCh {
+			if outage, ok := event.Data.(Outage); ok {
+				record := map[string]interface{}{
+					"outage_start": clock.Milli(outage.Start),
+					"outage_end":   clock.Milli(outage.End),
+					"received_at":  clock.Milli(outage.End),
+				}
+				if outage.Err != nil {
+					record["error"] = outage.Err.Error()
+				}
+				outCh <- record
+				continue
+			}
+			if feedError, ok := event.Data.(FeedError); ok {
+				outCh <- map[string]interface{}{
+					"method":      feedError.Method,
+					"error":       feedError.Err.Error(),
+					"errors":      feedError.Count,
+					"received_at": clock.Milli(event.ReceivedAt),
+				}
+				continue
+			}
+			if observe != nil {
+				observe(event)
+			}
+			bytes, err := json.Marshal(event.Data)
+			if err != nil {
+				continue
+			}
+			record := make(map[string]interface{})
+			if err := json.Unmarshal(bytes, &record); err != nil {
+				continue
+			}
+			record["received_at"] = clock.Milli(event.ReceivedAt)
+			if event.LookupDelay > 0 {
+				record["lookup_delay"] = float64(event.LookupDelay.Microseconds()) / 1000
+			}
+			for key, value := range event.Extra {
+				record[key] = value
+			}
+			outCh <- record
+		}
+	}()
+	return outCh
+}
+
+// Observer returns a callback for Records() which feeds events to engine.
+func Observer(engine *stats.Engine) func(Event) {
+	return func(event Event) {
+		if event.Backfilled {
+			engine.Backfill(event.Source, event.Key)
+		} else {
+			engine.Observe(event.Source, event.Key, event.ReceivedAt)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelSource(t *testing.T) {
+	stopped := make(chan struct{})
+	source := &channelSource[common.Hash]{
+		name: "fake",
+		kind: KindBlock,
+		subscribe: func(ctx context.Context, _ hooks) (*utils.Subscription[common.Hash], error) {
+			return utils.Go(ctx, 2, func(ctx context.Context, hashCh chan<- common.Hash) error {
+				hashCh <- common.Hash{} // dropped
+				hashCh <- common.HexToHash("0x01")
+				<-ctx.Done()
+				close(stopped)
+				return nil
+			}), nil
+		},
+		toEvent: func(hash common.Hash) (string, interface{}, bool) {
+			if hash == (common.Hash{}) {
+				return "", nil, false
+			}
+			return hashEvent(hash)
+		},
+	}
+	assert.Equal(t, "fake", source.Name())
+	assert.Equal(t, KindBlock, source.Kind())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	eventCh, err := source.Start(ctx)
+	assert.NoError(t, err)
+
+	event := <-eventCh
+	assert.Equal(t, "fake", event.Source)
+	assert.Equal(t, KindBlock, event.Kind)
+	assert.Equal(t, common.HexToHash("0x01").Hex(), event.Key)
+	assert.Equal(t, map[string]common.Hash{"hash": common.HexToHash("0x01")}, event.Data)
+	assert.WithinDuration(t, time.Now(), event.ReceivedAt, time.Second)
+	assert.Equal(t, SourceStats{Received: 1, Errors: 1}, source.Stats())
+
+	cancel()
+	_, ok := <-eventCh
+	assert.False(t, ok)
+	// the subscription is released before eventCh is closed
+	select {
+	case <-stopped:
+	default:
+		assert.Fail(t, "the subscription is still running")
+	}
+}
+
+func TestRecordsOutage(t *testing.T) {
+	clock := utils.NewClock()
+	start := time.Now()
+	eventCh := make(chan Event, 1)
+	eventCh <- Event{Source: "fake", Kind: KindBlock, ReceivedAt: start.Add(time.Second), Data: Outage{
+		Start: start,
+		End:   start.Add(time.Second),
+		Err:   errors.New("EOF"),
+	}}
+	close(eventCh)
+
+	observed := 0
+	record := <-Records(eventCh, clock, func(Event) { observed++ })
+	assert.Equal(t, 0, observed) // outages are not records
+	assert.Equal(t, clock.Milli(start), record["outage_start"])
+	assert.Equal(t, clock.Milli(start.Add(time.Second)), record["outage_end"])
+	assert.Equal(t, "EOF", record["error"])
+}
+
+func TestRecordsFeedError(t *testing.T) {
+	clock := utils.NewClock()
+	eventCh := make(chan Event, 1)
+	eventCh <- Event{Source: "fake", Kind: KindReserve, ReceivedAt: time.Now(), Data: FeedError{
+		Method: "eth_call",
+		Err:    errors.New("timeout"),
+		Count:  3,
+	}}
+	close(eventCh)
+
+	record := <-Records(eventCh, clock, nil)
+	assert.Equal(t, "eth_call", record["method"])
+	assert.Equal(t, "timeout", record["error"])
+	assert.Equal(t, uint64(3), record["errors"])
+}
+
+func TestRecordsLookupDelay(t *testing.T) {
+	clock := utils.NewClock()
+	notifiedAt := time.Now()
+	eventCh := make(chan Event, 2)
+	eventCh <- Event{Source: "fake", Kind: KindTx, ReceivedAt: notifiedAt, LookupDelay: 1500 * time.Microsecond, Data: map[string]string{"hash": "0x01"}}
+	eventCh <- Event{Source: "fake", Kind: KindTx, ReceivedAt: notifiedAt, Data: map[string]string{"hash": "0x02"}}
+	close(eventCh)
+
+	recordCh := Records(eventCh, clock, nil)
+	record := <-recordCh
+	assert.Equal(t, clock.Milli(notifiedAt), record["received_at"])
+	assert.Equal(t, 1.5, record["lookup_delay"])
+	record = <-recordCh
+	assert.NotContains(t, record, "lookup_delay")
+}
+
+func TestRecordsDecode(t *testing.T) {
+	clock := utils.NewClock()
+	registry := utils.NewSelectorRegistry()
+	assert.NoError(t, registry.AddSignature("approve(address,uint256)"))
+	to := common.HexToAddress("0x01")
+	approve := types.NewTransaction(0, to, big.NewInt(0), 21000, big.NewInt(1), common.FromHex("0x095ea7b3"))
+	transfer := types.NewTransaction(1, to, big.NewInt(1), 21000, big.NewInt(1), nil)
+	eventCh := make(chan Event, 3)
+	eventCh <- Event{Source: "fake", Kind: KindTx, Data: pojo.NewRawTransaction(approve, "fake")}
+	eventCh <- Event{Source: "fake", Kind: KindTx, Data: pojo.NewRawTransaction(transfer, "fake")}
+	eventCh <- Event{Source: "fake", Kind: KindTx, Data: Outage{}}
+	close(eventCh)
+
+	recordCh := Records(Decode(eventCh, registry), clock, nil)
+	record := <-recordCh
+	assert.Equal(t, "approve", record["method"])
+	assert.Equal(t, "0x095ea7b3", record["selector"])
+	assert.NotContains(t, record, "args")
+	record = <-recordCh
+	assert.NotContains(t, record, "method")
+	assert.NotContains(t, record, "selector")
+	record = <-recordCh
+	assert.Contains(t, record, "outage_start")
+}
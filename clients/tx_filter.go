@@ -0,0 +1,272 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TxFilter selects pending transactions the same way for every source, so
+// that filtered benchmarks are comparable across providers.
+//
+// A transaction matches if it is sent to any of To or from any of From, like
+// the whitelists of SubscribePendingTx(), and meets all other conditions.
+// Empty conditions are ignored.
+type TxFilter struct {
+	To           []common.Address         `json:"to,omitempty"`
+	From         []common.Address         `json:"from,omitempty"`
+	Methods      []utils.FunctionSelector `json:"methods,omitempty"`       // selectors or signatures
+	MinValue     *big.Int                 `json:"min_value,omitempty"`     // in wei, inclusive
+	MinGasPrice  *big.Int                 `json:"min_gas_price,omitempty"` // in wei, inclusive
+	MaxGasPrice  *big.Int                 `json:"max_gas_price,omitempty"` // in wei, inclusive
+	ContractOnly bool                     `json:"contract_only,omitempty"` // calls with data only
+}
+
+// ParseTxFilter parses a filter expression of space separated terms, e.g.,
+//
+//	to=0x10ED43C718714eb63d5aA57B78B54704E256024E method=0x38ed1739,approve(address,uint256) min_value=1e18 gas_price=5e9..10e9 contract
+//
+// Terms are to, from and method with comma separated lists, min_value,
+// gas_price with a range of which either end may be omitted, and contract.
+// Amounts are in wei, in decimal, hex or scientific notation.
+func ParseTxFilter(expr string) (*TxFilter, error) {
+	filter := &TxFilter{}
+	for _, term := range strings.Fields(expr) {
+		key, value, _ := strings.Cut(term, "=")
+		var err error
+		switch key {
+		case "to":
+			filter.To, err = parseAddresses(value)
+		case "from":
+			filter.From, err = parseAddresses(value)
+		case "method":
+			for _, method := range splitMethods(value) {
+				var selector utils.FunctionSelector
+				if err = selector.UnmarshalJSON([]byte(`"` + method + `"`)); err != nil {
+					break
+				}
+				filter.Methods = append(filter.Methods, selector)
+			}
+		case "min_value":
+			filter.MinValue, err = parseWei(value)
+		case "gas_price":
+			low, high, ok := strings.Cut(value, "..")
+			if !ok {
+				return nil, fmt.Errorf("gas_price is not a range: %s", value)
+			}
+			if low != "" {
+				if filter.MinGasPrice, err = parseWei(low); err != nil {
+					break
+				}
+			}
+			if high != "" {
+				filter.MaxGasPrice, err = parseWei(high)
+			}
+		case "contract":
+			if value != "" {
+				return nil, fmt.Errorf("contract takes no value: %s", term)
+			}
+			filter.ContractOnly = true
+		default:
+			return nil, fmt.Errorf("unknown filter term: %s", term)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", term, err)
+		}
+	}
+	return filter, nil
+}
+
+// UnmarshalJSON accepts an expression of ParseTxFilter() as well as an
+// object.
+func (f *TxFilter) UnmarshalJSON(data []byte) error {
+	var expr string
+	if err := json.Unmarshal(data, &expr); err == nil {
+		filter, err := ParseTxFilter(expr)
+		if err != nil {
+			return err
+		}
+		*f = *filter
+		return nil
+	}
+	type txFilter TxFilter // without UnmarshalJSON
+	return json.Unmarshal(data, (*txFilter)(f))
+}
+
+func parseAddresses(value string) ([]common.Address, error) {
+	addresses := make([]common.Address, 0)
+	for _, s := range strings.Split(value, ",") {
+		if !common.IsHexAddress(s) {
+			return nil, fmt.Errorf("invalid address: %s", s)
+		}
+		addresses = append(addresses, common.HexToAddress(s))
+	}
+	return addresses, nil
+}
+
+// Split on commas outside of parentheses, which separate signature types.
+func splitMethods(value string) []string {
+	methods := make([]string, 0)
+	depth, start := 0, 0
+	for i, c := range value {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				methods = append(methods, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(methods, value[start:])
+}
+
+func parseWei(s string) (*big.Int, error) {
+	if n, ok := new(big.Int).SetString(s, 0); ok {
+		return n, nil
+	}
+	f, ok := new(big.Float).SetPrec(256).SetString(s)
+	if !ok || !f.IsInt() || f.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount: %s", s)
+	}
+	n, _ := f.Int(nil)
+	return n, nil
+}
+
+// Compile returns a function which tells whether a transaction matches, it
+// is safe for concurrent use. The sender is recovered only if it matters.
+func (f *TxFilter) Compile() func(pojo.TxData) bool {
+	toSet := addressSet(f.To)
+	fromSet := addressSet(f.From)
+	methodSet := make(map[utils.FunctionSelector]bool)
+	for _, method := range f.Methods {
+		methodSet[method] = true
+	}
+	filter := *f
+
+	return func(tx pojo.TxData) bool {
+		data := tx.Data()
+		if filter.ContractOnly && (tx.To() == nil || len(data) == 0) {
+			return false
+		}
+		if len(methodSet) > 0 && (len(data) < utils.FunctionSelectorLength || !methodSet[utils.BytesToFunctionSelector(data)]) {
+			return false
+		}
+		if filter.MinValue != nil && tx.Value().Cmp(filter.MinValue) < 0 {
+			return false
+		}
+		if filter.MinGasPrice != nil && tx.GasPrice().Cmp(filter.MinGasPrice) < 0 {
+			return false
+		}
+		if filter.MaxGasPrice != nil && tx.GasPrice().Cmp(filter.MaxGasPrice) > 0 {
+			return false
+		}
+		if len(toSet) == 0 && len(fromSet) == 0 {
+			return true
+		}
+		if to := tx.To(); to != nil && toSet[*to] {
+			return true
+		}
+		if len(fromSet) > 0 {
+			from := tx.From()
+			return from != nil && fromSet[*from]
+		}
+		return false
+	}
+}
+
+func addressSet(addresses []common.Address) map[common.Address]bool {
+	set := make(map[common.Address]bool, len(addresses))
+	for _, address := range addresses {
+		set[address] = true
+	}
+	return set
+}
+
+// BloXroute translates the filter to the filters of bloXroute tx streams,
+// see https://docs.bloxroute.com/streams/newtxs-and-pendingtxs/filters.
+//
+// ContractOnly can't be translated and is left out, so the stream is a
+// superset and has to be filtered again by FilterTx().
+func (f *TxFilter) BloXroute() string {
+	conditions := make([]string, 0)
+	addresses := make([]string, 0, 2)
+	if len(f.To) > 0 {
+		addresses = append(addresses, fmt.Sprintf("({to} IN [%s])", quoteAddresses(f.To)))
+	}
+	if len(f.From) > 0 {
+		addresses = append(addresses, fmt.Sprintf("({from} IN [%s])", quoteAddresses(f.From)))
+	}
+	if len(addresses) > 0 {
+		conditions = append(conditions, "("+strings.Join(addresses, " OR ")+")")
+	}
+	if len(f.Methods) > 0 {
+		methods := make([]string, len(f.Methods))
+		for i, method := range f.Methods {
+			methods[i] = "'" + strings.TrimPrefix(method.String(), "0x") + "'"
+		}
+		conditions = append(conditions, fmt.Sprintf("({method_id} IN [%s])", strings.Join(methods, ", ")))
+	}
+	if f.MinValue != nil {
+		conditions = append(conditions, fmt.Sprintf("({value} >= %s)", f.MinValue))
+	}
+	if f.MinGasPrice != nil {
+		conditions = append(conditions, fmt.Sprintf("({gas_price} >= %s)", f.MinGasPrice))
+	}
+	if f.MaxGasPrice != nil {
+		conditions = append(conditions, fmt.Sprintf("({gas_price} <= %s)", f.MaxGasPrice))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func quoteAddresses(addresses []common.Address) string {
+	quoted := make([]string, len(addresses))
+	for i, address := range addresses {
+		quoted[i] = "'" + strings.ToLower(address.Hex()) + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// FilterTx drops transaction events not matching filter, and records which
+// can't be evaluated because they are not pojo.TxData, e.g., hashes and
+// undecodable bloXroute transactions. Outages and errors pass through.
+func FilterTx(eventCh <-chan Event, filter *TxFilter) <-chan Event {
+	match := filter.Compile()
+	outCh := make(chan Event, 1024)
+	go func() {
+		defer close(outCh)
+		for event := range eventCh {
+			switch data := event.Data.(type) {
+			case Outage, FeedError:
+			case pojo.TxData:
+				if !match(data) {
+					continue
+				}
+			default:
+				continue
+			}
+			outCh <- event
+		}
+	}()
+	return outCh
+}
+
+// ApplyTxFilter is FilterTx() preceded by RecoverSenders() with workers if
+// filter has From, events pass through if filter is nil.
+func ApplyTxFilter(eventCh <-chan Event, filter *TxFilter, workers int) <-chan Event {
+	if filter == nil {
+		return eventCh
+	}
+	if len(filter.From) > 0 {
+		eventCh = RecoverSenders(eventCh, workers)
+	}
+	return FilterTx(eventCh, filter)
+}
@@ -0,0 +1,134 @@
+package clients
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	bloXrouteTypes "github.com/crypto-crawler/bloxroute-go/types"
+	"github.com/crypto-crawler/fullnode-benchmarks/constant"
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+var router = common.HexToAddress(constant.PANCAKESWAP_V2_ROUTER_ADDRESS)
+
+func TestParseTxFilter(t *testing.T) {
+	filter, err := ParseTxFilter("to=0x10ED43C718714eb63d5aA57B78B54704E256024E method=0x38ed1739,approve(address,uint256) min_value=1e18 gas_price=5e9.. contract")
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Address{router}, filter.To)
+	assert.Equal(t, []utils.FunctionSelector{utils.HexToFunctionSelector("0x38ed1739"), utils.HexToFunctionSelector("0x095ea7b3")}, filter.Methods)
+	assert.Equal(t, "1000000000000000000", filter.MinValue.String())
+	assert.Equal(t, big.NewInt(5e9), filter.MinGasPrice)
+	assert.Nil(t, filter.MaxGasPrice)
+	assert.True(t, filter.ContractOnly)
+
+	filter, err = ParseTxFilter("gas_price=..0x2540be400")
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1e10), filter.MaxGasPrice)
+
+	for _, expr := range []string{"to=0x01", "gas_price=5e9", "min_value=1.5", "contract=yes", "nonce=1", "method=0x1234"} {
+		_, err := ParseTxFilter(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestTxFilterUnmarshalJSON(t *testing.T) {
+	var filters []TxFilter
+	assert.NoError(t, json.Unmarshal([]byte(`["contract min_value=1", {"contract_only": true, "min_value": 1}]`), &filters))
+	assert.Equal(t, filters[0], filters[1])
+	assert.Error(t, json.Unmarshal([]byte(`"contract=1"`), &TxFilter{}))
+}
+
+func TestTxFilterCompile(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.LatestSignerForChainID(big.NewInt(56))
+	newTx := func(to *common.Address, value int64, gasPrice int64, data string) pojo.TxData {
+		tx := types.MustSignNewTx(key, signer, &types.LegacyTx{To: to, Value: big.NewInt(value), Gas: 21000, GasPrice: big.NewInt(gasPrice), Data: common.FromHex(data)})
+		return pojo.NewRawTransaction(tx, "test")
+	}
+	other := common.HexToAddress("0x01")
+	swap := newTx(&router, 100, 5e9, "0x38ed1739")
+	transfer := newTx(&other, 1000, 10e9, "")
+	deploy := newTx(nil, 0, 5e9, "0x6080")
+
+	for _, test := range []struct {
+		expr    string
+		matched []bool // swap, transfer, deploy
+	}{
+		{"", []bool{true, true, true}},
+		{"contract", []bool{true, false, false}},
+		{"to=" + router.Hex(), []bool{true, false, false}},
+		{"from=" + sender.Hex(), []bool{true, true, true}},
+		{"to=" + other.Hex() + " from=" + other.Hex(), []bool{false, true, false}},
+		{"method=swapExactTokensForTokens(uint256,uint256,address[],address,uint256)", []bool{true, false, false}},
+		{"min_value=1000", []bool{false, true, false}},
+		{"gas_price=6e9..", []bool{false, true, false}},
+		{"gas_price=..6e9", []bool{true, false, true}},
+	} {
+		filter, err := ParseTxFilter(test.expr)
+		assert.NoError(t, err)
+		match := filter.Compile()
+		assert.Equal(t, test.matched, []bool{match(swap), match(transfer), match(deploy)}, test.expr)
+	}
+}
+
+func TestTxFilterBloXroute(t *testing.T) {
+	filter, err := ParseTxFilter("to=0x10ED43C718714eb63d5aA57B78B54704E256024E from=0x0000000000000000000000000000000000000001 method=0x38ed1739 min_value=1 gas_price=5e9..1e10 contract")
+	assert.NoError(t, err)
+	assert.Equal(t, "(({to} IN ['0x10ed43c718714eb63d5aa57b78b54704e256024e']) OR ({from} IN ['0x0000000000000000000000000000000000000001'])) AND ({method_id} IN ['38ed1739']) AND ({value} >= 1) AND ({gas_price} >= 5000000000) AND ({gas_price} <= 10000000000)", filter.BloXroute())
+	assert.Equal(t, "", (&TxFilter{ContractOnly: true}).BloXroute())
+}
+
+func TestFilterTx(t *testing.T) {
+	tx := func(value int64) pojo.TxData {
+		return pojo.NewRawTransaction(types.NewTransaction(0, router, big.NewInt(value), 21000, big.NewInt(1), nil), "test")
+	}
+	eventCh := make(chan Event, 4)
+	eventCh <- Event{Key: "small", Data: tx(1)}
+	eventCh <- Event{Key: "hash", Data: map[string]common.Hash{"hash": {}}}
+	eventCh <- Event{Key: "outage", Data: Outage{}}
+	eventCh <- Event{Key: "large", Data: tx(100)}
+	close(eventCh)
+
+	keys := make([]string, 0)
+	for event := range FilterTx(eventCh, &TxFilter{MinValue: big.NewInt(100)}) {
+		keys = append(keys, event.Key)
+	}
+	assert.Equal(t, []string{"outage", "large"}, keys)
+}
+
+func TestApplyTxFilter(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	signed := types.MustSignNewTx(key, types.LatestSignerForChainID(big.NewInt(56)), &types.LegacyTx{To: &router, Gas: 21000, GasPrice: big.NewInt(1)})
+	eventCh := make(chan Event, 2)
+	eventCh <- Event{Key: "hash", Data: map[string]common.Hash{"hash": {}}}
+	eventCh <- Event{Key: "signed", Data: pojo.NewRawTransaction(signed, "test")}
+	close(eventCh)
+
+	assert.Equal(t, (<-chan Event)(eventCh), ApplyTxFilter(eventCh, nil, 2))
+	keys := make([]string, 0)
+	for event := range ApplyTxFilter(eventCh, &TxFilter{From: []common.Address{sender}}, 2) {
+		keys = append(keys, event.Key)
+	}
+	assert.Equal(t, []string{"signed"}, keys)
+}
+
+func TestBloXrouteTxDecodeError(t *testing.T) {
+	source := NewBloXrouteTxSource("bloxroute-tx", BloXrouteConfig{}, nil).(*channelSource[*bloXrouteTypes.Transaction])
+	before := promtestutil.ToFloat64(metrics.DecodeErrors.WithLabelValues("bloxroute"))
+	msg := &bloXrouteTypes.Transaction{TxHash: "0x01", RawTx: "0x01"}
+	key, data, ok := source.toEvent(msg)
+	assert.True(t, ok)
+	assert.Equal(t, "0x01", key)
+	assert.Equal(t, msg, data) // not pojo.TxData, dropped by FilterTx
+	assert.Equal(t, before+1, promtestutil.ToFloat64(metrics.DecodeErrors.WithLabelValues("bloxroute")))
+}
@@ -0,0 +1,191 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// What to do with a hash when the lookup queue is full.
+type DropPolicy string
+
+const (
+	DropPolicyBlock  DropPolicy = "block"  // wait for room, the subscription falls behind
+	DropPolicyNewest DropPolicy = "newest" // drop the incoming hash
+	DropPolicyOldest DropPolicy = "oldest" // drop the oldest queued hash
+)
+
+func ParseDropPolicy(s string) (DropPolicy, error) {
+	switch policy := DropPolicy(s); policy {
+	case DropPolicyBlock, DropPolicyNewest, DropPolicyOldest:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid drop policy: %s", s)
+	}
+}
+
+// How SubscribePendingTx() looks up hashes if the fullnode doesn't notify
+// full transactions, zero values are replaced by DefaultLookupOptions.
+type LookupOptions struct {
+	Workers   int // concurrent batch requests
+	QueueSize int // hashes waiting for lookup
+	BatchSize int // at most this number of hashes per batch request
+	// How long to collect hashes for a batch after the first one, a batch is
+	// sent right away once it is full.
+	Window time.Duration
+	Drop   DropPolicy // when the queue is full
+}
+
+var DefaultLookupOptions = LookupOptions{
+	Workers:   16,
+	QueueSize: 4096,
+	BatchSize: 32,
+	Window:    time.Millisecond,
+	Drop:      DropPolicyOldest,
+}
+
+func (o LookupOptions) withDefaults() LookupOptions {
+	if o.Workers <= 0 {
+		o.Workers = DefaultLookupOptions.Workers
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultLookupOptions.QueueSize
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultLookupOptions.BatchSize
+	}
+	if o.Window <= 0 {
+		o.Window = DefaultLookupOptions.Window
+	}
+	if o.Drop == "" {
+		o.Drop = DefaultLookupOptions.Drop
+	}
+	return o
+}
+
+// Lookups of a hash, the transaction might not be found right after the hash
+// is notified.
+const pendingTxLookups = 6
+
+// A hash to look up with the connection it was notified on.
+type hashLookup struct {
+	rpcClient  *rpc.Client
+	hash       common.Hash
+	notifiedAt time.Time
+}
+
+// A bounded queue of hashes to look up, the producer applies the drop policy.
+type lookupQueue struct {
+	ch     chan hashLookup
+	policy DropPolicy
+	onDrop func(hash common.Hash) // optional
+}
+
+func newLookupQueue(size int, policy DropPolicy, onDrop func(hash common.Hash)) *lookupQueue {
+	return &lookupQueue{ch: make(chan hashLookup, size), policy: policy, onDrop: onDrop}
+}
+
+func (q *lookupQueue) drop(l hashLookup) {
+	metrics.Drops.WithLabelValues("tx_lookup").Inc()
+	if q.onDrop != nil {
+		q.onDrop(l.hash)
+	}
+}
+
+// Enqueue a lookup, returns false if ctx is done.
+func (q *lookupQueue) push(ctx context.Context, l hashLookup) bool {
+	for {
+		select {
+		case q.ch <- l:
+			return true
+		case <-ctx.Done():
+			return false
+		default:
+		}
+
+		switch q.policy {
+		case DropPolicyNewest:
+			q.drop(l)
+			return true
+		case DropPolicyOldest:
+			select {
+			case oldest := <-q.ch:
+				q.drop(oldest)
+			default: // taken by a worker in the meantime
+			}
+		default:
+			select {
+			case q.ch <- l:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}
+}
+
+// Wait for a lookup, then collect more for window, up to n in total.
+// Returns nil after the queue is closed.
+func (q *lookupQueue) pop(n int, window time.Duration) []hashLookup {
+	l, ok := <-q.ch
+	if !ok {
+		return nil
+	}
+	batch := []hashLookup{l}
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+	for len(batch) < n {
+		select {
+		case l, ok := <-q.ch:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, l)
+		case <-timer.C:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (q *lookupQueue) close() {
+	close(q.ch)
+}
+
+// Look up a batch of hashes with eth_getTransactionByHash, see batchCall(),
+// found is called on every transaction found as soon as its attempt returns.
+// Every hash which is not found, because of a failed request, an invalid
+// result or attempts running out, is counted by metrics.RPCErrors.
+//
+// The batch is sent on the connection of its latest hash, lookups on a
+// dropped connection would fail anyway.
+func lookupBatch(ctx context.Context, batch []hashLookup, found func(l hashLookup, tx *types.Transaction, pending bool)) {
+	const method = "eth_getTransactionByHash"
+	hashes := make([]common.Hash, len(batch))
+	for i, l := range batch {
+		hashes[i] = l.hash
+	}
+	emitted := 0
+	err := batchCall(ctx, batch[len(batch)-1].rpcClient, method, hashArgs(hashes), pendingTxLookups, decodeFetchedTx, func(i int, result BatchResult[*FetchedTx]) {
+		emitted++
+		if result.Found {
+			found(batch[i], result.Value.Transaction, result.Value.IsPending())
+			return
+		}
+		// not found in time usually happens when eth.syncing is not false
+		metrics.RPCErrors.WithLabelValues(method).Inc()
+		if result.Err != nil {
+			log.Printf("Failed to look up %s, error: %v", batch[i].hash.Hex(), result.Err)
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		metrics.RPCErrors.WithLabelValues(method).Add(float64(len(batch) - emitted))
+		log.Printf("Failed to look up %d transactions, error: %v", len(batch)-emitted, err)
+	}
+}
@@ -0,0 +1,95 @@
+package clients
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDropPolicy(t *testing.T) {
+	policy, err := ParseDropPolicy("newest")
+	assert.NoError(t, err)
+	assert.Equal(t, DropPolicyNewest, policy)
+	_, err = ParseDropPolicy("random")
+	assert.Error(t, err)
+}
+
+func TestLookupQueue(t *testing.T) {
+	ctx := context.Background()
+	lookup := func(n byte) hashLookup {
+		return hashLookup{hash: common.BytesToHash([]byte{n})}
+	}
+	hashes := func(batch []hashLookup) []common.Hash {
+		hashes := make([]common.Hash, 0, len(batch))
+		for _, l := range batch {
+			hashes = append(hashes, l.hash)
+		}
+		return hashes
+	}
+
+	for _, test := range []struct {
+		policy  DropPolicy
+		kept    []common.Hash
+		dropped []common.Hash
+	}{
+		{DropPolicyNewest, []common.Hash{lookup(1).hash, lookup(2).hash}, []common.Hash{lookup(3).hash}},
+		{DropPolicyOldest, []common.Hash{lookup(2).hash, lookup(3).hash}, []common.Hash{lookup(1).hash}},
+	} {
+		dropped := make([]common.Hash, 0)
+		queue := newLookupQueue(2, test.policy, func(hash common.Hash) { dropped = append(dropped, hash) })
+		for n := byte(1); n <= 3; n++ {
+			assert.True(t, queue.push(ctx, lookup(n)))
+		}
+		queue.close()
+		assert.Equal(t, test.kept, hashes(queue.pop(10, time.Second)), test.policy)
+		assert.Nil(t, queue.pop(10, time.Second))
+		assert.Equal(t, test.dropped, dropped, test.policy)
+	}
+
+	// a batch is sent after the window even if it is not full
+	queue := newLookupQueue(2, DropPolicyBlock, nil)
+	assert.True(t, queue.push(ctx, lookup(1)))
+	start := time.Now()
+	assert.Equal(t, []common.Hash{lookup(1).hash}, hashes(queue.pop(10, 20*time.Millisecond)))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	// blocks until ctx is done
+	queue = newLookupQueue(1, DropPolicyBlock, nil)
+	assert.True(t, queue.push(ctx, lookup(1)))
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.False(t, queue.push(ctx, lookup(2)))
+	assert.Equal(t, []common.Hash{lookup(1).hash}, hashes(queue.pop(1, time.Second)))
+}
+
+func TestLookupBatch(t *testing.T) {
+	node, rpcClient, mined, pending := newBatchTestNode(t)
+	ctx := context.Background()
+	batch := []hashLookup{{hash: mined.Hash()}, {hash: pending.Hash()}, {hash: common.HexToHash("0x01"), rpcClient: rpcClient}}
+	rpcErrors := func() float64 {
+		return promtestutil.ToFloat64(metrics.RPCErrors.WithLabelValues("eth_getTransactionByHash"))
+	}
+
+	// the last hash is never found
+	before := rpcErrors()
+	found := make(map[common.Hash]bool)
+	lookupBatch(ctx, batch, func(l hashLookup, tx *types.Transaction, pending bool) {
+		found[l.hash] = pending
+	})
+	assert.Equal(t, map[common.Hash]bool{mined.Hash(): false, pending.Hash(): true}, found)
+	assert.Equal(t, before+1, rpcErrors())
+
+	// every hash of a failed batch request is counted
+	node.Drop()
+	before = rpcErrors()
+	lookupBatch(ctx, batch, func(l hashLookup, tx *types.Transaction, pending bool) {
+		assert.Fail(t, "found after the connection dropped")
+	})
+	assert.Equal(t, before+1+3, rpcErrors())
+}
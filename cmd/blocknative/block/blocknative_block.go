@@ -1,7 +1,77 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os/signal"
+	"syscall"
 
+	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/dashboard"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+)
+
+// Subscribe to new blocks from blocknative.com, the output has the same
+// format as fullnode-block.json.
 func main() {
-	fmt.Println("hello world")
+	apiKey := flag.String("apikey", "", "blocknative API key")
+	endpoint := flag.String("endpoint", clients.BlocknativeUrl, "The blocknative websocket endpoint")
+	network := flag.String("network", "bsc-main", "The blocknative network, e.g., main, bsc-main")
+	filters := flag.String("filters", "", `Filters of confirmed transactions in JSON, defaults to [{"status":"confirmed"}]`)
+	outputFile := flag.String("output", "blocknative-block.json", "The output file")
+	httpAddr := flag.String("http", "", "Serve the dashboard on this address if present, e.g., :8080")
+	flag.Parse()
+	if *apiKey == "" || *outputFile == "" {
+		flag.Usage()
+		return
+	}
+
+	config := clients.BlocknativeConfig{
+		ApiKey:  *apiKey,
+		Url:     *endpoint,
+		Network: *network,
+	}
+	if *filters != "" {
+		if err := json.Unmarshal([]byte(*filters), &config.Filters); err != nil {
+			log.Fatalf("Invalid filters: %v", err)
+		}
+	}
+
+	// catch Ctrl+C
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	source := clients.NewBlocknativeBlockSource("blocknative-block", config)
+	eventCh, err := source.Start(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var observe func(clients.Event)
+	if *httpAddr != "" {
+		engine, err := stats.NewEngine(1 << 16)
+		if err != nil {
+			log.Fatal(err)
+		}
+		observe = clients.Observer(engine)
+		go dashboard.NewServer(engine, []clients.Source{source}, nil).ListenAndServe(ctx, *httpAddr)
+	}
+
+	writer, err := utils.Run(clients.Records(eventCh, utils.NewClock(), observe), *outputFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Println("Ctrl+C detected, exiting...")
+	case <-writer.Done(): // the source stopped by itself
+	}
+	// eventCh is closed after the source is released, then the writer flushes
+	if err := writer.Wait(); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -1,48 +1,94 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"log"
-	"os"
 	"os/signal"
+	"runtime"
 	"syscall"
-	"time"
 
 	"github.com/crypto-crawler/fullnode-benchmarks/clients"
-	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/dashboard"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
 	"github.com/crypto-crawler/fullnode-benchmarks/utils"
 )
 
 // Subscribe to pending transactions from blocknative.com
 func main() {
 	apiKey := flag.String("apikey", "", "blocknative API key")
+	endpoint := flag.String("endpoint", clients.BlocknativeUrl, "The blocknative websocket endpoint")
+	network := flag.String("network", "bsc-main", "The blocknative network, e.g., main, bsc-main")
+	watchRouter := flag.Bool("router", true, "Watch addLiquidity calls to the PancakeSwap V2 router, only on bsc-main")
+	filters := flag.String("filters", "", `Filters of a global config in JSON, e.g., [{"status":"pending"}]`)
 	outputFile := flag.String("output", "blocknative-tx.json", "The output file")
+	httpAddr := flag.String("http", "", "Serve the dashboard on this address if present, e.g., :8080")
+	abiFiles := flag.String("abi", "", "Comma-separated ABI files to decode calls with, in addition to the PancakeSwap router and pair")
+	signatureFiles := flag.String("signatures", "", "Comma-separated files of function signatures, one per line, to name calls with")
+	filterExpr := flag.String("filter", "", "Only keep matching transactions, e.g., \"to=0x10ED43C718714eb63d5aA57B78B54704E256024E contract\", see clients.ParseTxFilter()")
 	flag.Parse()
 	if *apiKey == "" || *outputFile == "" {
 		flag.Usage()
 		return
 	}
 
-	client, err := clients.NewBlocknativeClient(*apiKey, "ethereum", "bsc-main", nil, nil)
+	registry, err := utils.LoadSelectorRegistry(*abiFiles, *signatureFiles)
 	if err != nil {
 		log.Fatal(err)
 	}
+	var filter *clients.TxFilter
+	if *filterExpr != "" {
+		if filter, err = clients.ParseTxFilter(*filterExpr); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// catch Ctrl+C
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-	stopCh := make(chan struct{})
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-	pendingTxCh := make(chan pojo.TxData)
-	err = client.Subscribe(stopCh, pendingTxCh)
+	config := clients.BlocknativeConfig{
+		ApiKey:      *apiKey,
+		Url:         *endpoint,
+		Network:     *network,
+		WatchRouter: *watchRouter && *network == "bsc-main",
+	}
+	if *filters != "" {
+		if err := json.Unmarshal([]byte(*filters), &config.Filters); err != nil {
+			log.Fatalf("Invalid filters: %v", err)
+		}
+	}
+	source := clients.NewBlocknativeTxSource("blocknative-tx", config)
+	eventCh, err := source.Start(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	eventCh = clients.ApplyTxFilter(eventCh, filter, runtime.NumCPU())
+	eventCh = clients.Decode(eventCh, registry)
 
-	go utils.Run(pendingTxCh, stopCh, *outputFile)
+	var observe func(clients.Event)
+	if *httpAddr != "" {
+		engine, err := stats.NewEngine(1 << 16)
+		if err != nil {
+			log.Fatal(err)
+		}
+		observe = clients.Observer(engine)
+		go dashboard.NewServer(engine, []clients.Source{source}, nil).ListenAndServe(ctx, *httpAddr)
+	}
 
-	<-signals
-	log.Println("Ctrl+C detected, exiting...")
-	close(stopCh)
-	time.Sleep(1 * time.Second) // give some time for other goroutines to stop
+	writer, err := utils.Run(clients.Records(eventCh, utils.NewClock(), observe), *outputFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Println("Ctrl+C detected, exiting...")
+	case <-writer.Done(): // the source stopped by itself
+	}
+	// eventCh is closed after the source is released, then the writer flushes
+	if err := writer.Wait(); err != nil {
+		log.Fatal(err)
+	}
 }
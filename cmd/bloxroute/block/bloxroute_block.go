@@ -1,15 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
-	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	"github.com/crypto-crawler/bloxroute-go/client"
-	"github.com/crypto-crawler/bloxroute-go/types"
+	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/dashboard"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
 	"github.com/crypto-crawler/fullnode-benchmarks/utils"
 )
 
@@ -22,6 +22,7 @@ func main() {
 	outputFile := flag.String("output", "bloxroute-block-cloud.json", "The output file")
 	gatewayUrl := flag.String("gateway", "", "The gateway url")
 	header := flag.String("header", "", "The authorization header")
+	httpAddr := flag.String("http", "", "Serve the dashboard on this address if present, e.g., :8080")
 	flag.Parse()
 	if *outputFile == "" {
 		log.Println("-output is empty!")
@@ -43,33 +44,49 @@ func main() {
 	}
 
 	// catch Ctrl+C
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-	stopCh := make(chan struct{})
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-	var bloXrouteClient *client.BloXrouteClient = nil
-	var err error = nil
+	config := clients.BloXrouteConfig{
+		Network: "BSC-Mainnet",
+		Cert:    *certFile,
+		Key:     *keyFile,
+		Gateway: *gatewayUrl,
+		Header:  *header,
+	}
 	if *gatewayUrl == "" {
 		log.Println("Connecting to bloXroute cloud")
-		bloXrouteClient, err = client.NewBloXrouteClientToCloud("BSC-Mainnet", *certFile, *keyFile, stopCh)
 	} else {
 		log.Println("Connecting to bloXroute gateway")
-		bloXrouteClient, err = client.NewBloXrouteClientToGateway(*gatewayUrl, *header, stopCh)
 	}
+	source := clients.NewBloXrouteBlockSource("bloxroute-block", config)
+	eventCh, err := source.Start(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	pendingBlockCh := make(chan *types.Block)
-	_, err = bloXrouteClient.SubscribeBdnBlocks([]string{"hash"}, pendingBlockCh)
+	var observe func(clients.Event)
+	if *httpAddr != "" {
+		engine, err := stats.NewEngine(1 << 16)
+		if err != nil {
+			log.Fatal(err)
+		}
+		observe = clients.Observer(engine)
+		go dashboard.NewServer(engine, []clients.Source{source}, nil).ListenAndServe(ctx, *httpAddr)
+	}
+
+	writer, err := utils.Run(clients.Records(eventCh, utils.NewClock(), observe), *outputFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	go utils.Run(pendingBlockCh, stopCh, *outputFile)
-
-	<-signals
-	log.Println("Ctrl+C detected, exiting...")
-	close(stopCh)
-	time.Sleep(1 * time.Second) // give some time for other goroutines to stop
+	select {
+	case <-ctx.Done():
+		log.Println("Ctrl+C detected, exiting...")
+	case <-writer.Done(): // the source stopped by itself
+	}
+	// eventCh is closed after the source is released, then the writer flushes
+	if err := writer.Wait(); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -1,21 +1,20 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
-	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	"github.com/crypto-crawler/bloxroute-go/client"
-	bloxroute_types "github.com/crypto-crawler/bloxroute-go/types"
-	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/dashboard"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
 	"github.com/crypto-crawler/fullnode-benchmarks/utils"
 	"github.com/ethereum/go-ethereum/common"
 )
 
-// Subscribe to pending transactions from the `newTxs` stream of bloXroute gateway or cloud API.
+// Subscribe to pair reserves from the `ethOnBlock` stream of bloXroute gateway or cloud API.
 func main() {
 	certFile := flag.String("cert", "external_gateway_cert.pem", "The cert file")
 	keyFile := flag.String("key", "external_gateway_key.pem", "The key file")
@@ -23,6 +22,7 @@ func main() {
 	pairFile := flag.String("pairs", "pairs.txt.gz", "The pairs file")
 	gatewayUrl := flag.String("gateway", "", "The gateway url")
 	header := flag.String("header", "", "The authorization header")
+	httpAddr := flag.String("http", "", "Serve the dashboard on this address if present, e.g., :8080")
 	flag.Parse()
 	if *outputFile == "" {
 		log.Println("-output is empty!")
@@ -44,9 +44,8 @@ func main() {
 	}
 
 	// catch Ctrl+C
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-	stopCh := make(chan struct{})
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
 	pairs := []common.Address{
 		common.HexToAddress("0x58f876857a02d6762e0101bb5c46a8c1ed44dc16"),
@@ -63,43 +62,46 @@ func main() {
 		pairs = arr
 	}
 
-	var bloXrouteClient *client.BloXrouteClient = nil
-	var err error = nil
+	config := clients.BloXrouteConfig{
+		Network: "BSC-Mainnet",
+		Cert:    *certFile,
+		Key:     *keyFile,
+		Gateway: *gatewayUrl,
+		Header:  *header,
+	}
 	if *gatewayUrl == "" {
 		log.Println("Connecting to bloXroute cloud")
-		bloXrouteClient, err = client.NewBloXrouteClientToCloud("BSC-Mainnet", *certFile, *keyFile, stopCh)
 	} else {
 		log.Println("Connecting to bloXroute gateway")
-		bloXrouteClient, err = client.NewBloXrouteClientToGateway(*gatewayUrl, *header, stopCh)
 	}
+	source := clients.NewBloXrouteReserveSource("bloxroute-pair-reserve", config, pairs)
+	eventCh, err := source.Start(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
-	bloXrouteClientEx := client.NewBloXrouteClientExtended(bloXrouteClient, stopCh)
 
-	outCh := make(chan *bloxroute_types.PairReserves)
-	reserveCh := make(chan *pojo.PairReserve)
-	go func() {
-		for x := range outCh {
-			pairReserve := &pojo.PairReserve{
-				Pair:               x.Pair,
-				Reserve0:           pojo.NewBigInt(x.Reserve0),
-				Reserve1:           pojo.NewBigInt(x.Reserve1),
-				BlockNumber:        x.BlockNumber,
-				BlockTimestampLast: x.BlockTimestampLast,
-			}
-			reserveCh <- pairReserve
+	var observe func(clients.Event)
+	if *httpAddr != "" {
+		engine, err := stats.NewEngine(1 << 16)
+		if err != nil {
+			log.Fatal(err)
 		}
-	}()
-	err = bloXrouteClientEx.SubscribePairReservesForBenchmark(pairs, outCh)
+		observe = clients.Observer(engine)
+		go dashboard.NewServer(engine, []clients.Source{source}, nil).ListenAndServe(ctx, *httpAddr)
+	}
+
+	writer, err := utils.Run(clients.Records(eventCh, utils.NewClock(), observe), *outputFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	go utils.Run(reserveCh, stopCh, *outputFile)
-
-	<-signals
-	log.Println("Ctrl+C detected, exiting...")
-	close(stopCh)
-	time.Sleep(1 * time.Second) // give some time for other goroutines to stop
+	select {
+	case <-ctx.Done():
+		log.Println("Ctrl+C detected, exiting...")
+	case <-writer.Done(): // the source stopped by itself
+	}
+	// eventCh is closed after the source is released, then the writer flushes
+	if err := writer.Wait(); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
-	"os"
 	"os/signal"
+	"runtime"
 	"syscall"
-	"time"
 
-	"github.com/crypto-crawler/bloxroute-go/client"
-	"github.com/crypto-crawler/bloxroute-go/types"
+	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/dashboard"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
 	"github.com/crypto-crawler/fullnode-benchmarks/utils"
 )
 
@@ -22,6 +23,10 @@ func main() {
 	outputFile := flag.String("output", "bloxroute-newtxs-cloud.json", "The output file")
 	gatewayUrl := flag.String("gateway", "", "The gateway url")
 	header := flag.String("header", "", "The authorization header")
+	httpAddr := flag.String("http", "", "Serve the dashboard on this address if present, e.g., :8080")
+	abiFiles := flag.String("abi", "", "Comma-separated ABI files to decode calls with, in addition to the PancakeSwap router and pair")
+	signatureFiles := flag.String("signatures", "", "Comma-separated files of function signatures, one per line, to name calls with")
+	filterExpr := flag.String("filter", "", "Only keep matching transactions, e.g., \"to=0x10ED43C718714eb63d5aA57B78B54704E256024E contract\", see clients.ParseTxFilter()")
 	flag.Parse()
 	if *outputFile == "" {
 		log.Println("-output is empty!")
@@ -42,34 +47,64 @@ func main() {
 		}
 	}
 
+	var filter *clients.TxFilter
+	if *filterExpr != "" {
+		var err error
+		if filter, err = clients.ParseTxFilter(*filterExpr); err != nil {
+			log.Fatal(err)
+		}
+	}
+	registry, err := utils.LoadSelectorRegistry(*abiFiles, *signatureFiles)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// catch Ctrl+C
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-	stopCh := make(chan struct{})
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-	var bloXrouteClient *client.BloXrouteClient = nil
-	var err error = nil
+	config := clients.BloXrouteConfig{
+		Network: "BSC-Mainnet",
+		Cert:    *certFile,
+		Key:     *keyFile,
+		Gateway: *gatewayUrl,
+		Header:  *header,
+	}
 	if *gatewayUrl == "" {
 		log.Println("Connecting to bloXroute cloud")
-		bloXrouteClient, err = client.NewBloXrouteClientToCloud("BSC-Mainnet", *certFile, *keyFile, stopCh)
 	} else {
 		log.Println("Connecting to bloXroute gateway")
-		bloXrouteClient, err = client.NewBloXrouteClientToGateway(*gatewayUrl, *header, stopCh)
 	}
+	source := clients.NewBloXrouteTxSource("bloxroute-newtxs", config, filter)
+	eventCh, err := source.Start(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	eventCh = clients.ApplyTxFilter(eventCh, filter, runtime.NumCPU())
+	eventCh = clients.Decode(eventCh, registry)
 
-	pendingTxCh := make(chan *types.Transaction)
-	_, err = bloXrouteClient.SubscribeNewTxs([]string{"tx_hash", "raw_tx"}, "", pendingTxCh)
+	var observe func(clients.Event)
+	if *httpAddr != "" {
+		engine, err := stats.NewEngine(1 << 16)
+		if err != nil {
+			log.Fatal(err)
+		}
+		observe = clients.Observer(engine)
+		go dashboard.NewServer(engine, []clients.Source{source}, nil).ListenAndServe(ctx, *httpAddr)
+	}
+
+	writer, err := utils.Run(clients.Records(eventCh, utils.NewClock(), observe), *outputFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	go utils.Run(pendingTxCh, stopCh, *outputFile)
-
-	<-signals
-	log.Println("Ctrl+C detected, exiting...")
-	close(stopCh)
-	time.Sleep(1 * time.Second) // give some time for other goroutines to stop
+	select {
+	case <-ctx.Done():
+		log.Println("Ctrl+C detected, exiting...")
+	case <-writer.Done(): // the source stopped by itself
+	}
+	// eventCh is closed after the source is released, then the writer flushes
+	if err := writer.Wait(); err != nil {
+		log.Fatal(err)
+	}
 }
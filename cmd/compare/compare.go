@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+)
+
+// Compare the received_at timestamps of two or more output files,
+// e.g., compare -lower 0.05 -upper 0.95 bloxroute-block-cloud.json.gz fullnode-block.json.gz
+func main() {
+	lower := flag.Float64("lower", 0.05, "Gaps below this quantile are removed as outliers")
+	upper := flag.Float64("upper", 0.95, "Gaps above this quantile are removed as outliers")
+	excludeOutages := flag.Bool("exclude-outages", true, "Remove records received while any source was reconnecting")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file1 file2 [file3 ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	files := flag.Args()
+	if len(files) < 2 || *lower < 0 || *upper > 1 || *lower >= *upper {
+		flag.Usage()
+		return
+	}
+
+	names := make([]string, len(files))
+	timestamps := make([]map[string]int64, len(files))
+	backfilled := make([]map[string]bool, len(files))
+	outages := make([]utils.Outage, 0)
+	for i, file := range files {
+		m, err := utils.ReadTimestamps(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		names[i] = utils.SourceName(file)
+		timestamps[i] = m
+
+		backfilled[i], err = utils.ReadBackfilled(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		arr, err := utils.ReadOutages(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		outages = append(outages, arr...)
+	}
+	if *excludeOutages {
+		if n := utils.ExcludeOutages(timestamps, outages); n > 0 {
+			fmt.Printf("Excluded %d records received during %d outages\n\n", n, len(outages))
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "file1\tfile2\tcount\tmean\tstd\tmin\tp50\tp90\tp99\tmax\twin1\twin2\t")
+	for i := 0; i < len(files); i++ {
+		for j := i + 1; j < len(files); j++ {
+			gaps := utils.CompareTimestamps(timestamps[i], timestamps[j])
+			summary := utils.SummarizeGaps(utils.TrimOutliers(gaps, *lower, *upper))
+			// win rates are computed before trimming, since outliers are still wins
+			win1, win2 := utils.HeadToHead(gaps)
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.2f\t%.2f\t%.0f\t%.1f\t%.1f\t%.1f\t%.0f\t%.2f%%\t%.2f%%\t\n",
+				names[i], names[j], summary.Count, summary.Mean, summary.Std,
+				summary.Min, summary.P50, summary.P90, summary.P99, summary.Max,
+				win1*100, win2*100)
+		}
+	}
+	w.Flush()
+
+	fmt.Println()
+	rates := utils.WinRates(timestamps)
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	// backfilled records count for completeness only
+	all := make(map[string]bool)
+	for i := range files {
+		for key := range timestamps[i] {
+			all[key] = true
+		}
+		for key := range backfilled[i] {
+			all[key] = true
+		}
+	}
+	fmt.Fprintln(w, "file\trecords\tbackfilled\tcoverage\twin rate\t")
+	for i := range files {
+		covered := len(timestamps[i])
+		for key := range backfilled[i] {
+			if _, ok := timestamps[i][key]; !ok {
+				covered++
+			}
+		}
+		coverage := 0.0
+		if len(all) > 0 {
+			coverage = float64(covered) / float64(len(all))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f%%\t%.2f%%\t\n", names[i], len(timestamps[i]), len(backfilled[i]), coverage*100, rates[i]*100)
+	}
+	w.Flush()
+}
@@ -1,22 +1,24 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/dashboard"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
 	"github.com/crypto-crawler/fullnode-benchmarks/utils"
-	"github.com/ethereum/go-ethereum/common"
 )
 
-// Subscribe to pending transactions from a standard fullnode.
+// Subscribe to new blocks from a standard fullnode.
 func main() {
 	fullNodeUrl := flag.String("fullnode", os.Getenv("FULLNODE_URL"), "The fullnode URL")
 	outputFile := flag.String("output", "fullnode-block.json", "The output file")
+	httpAddr := flag.String("http", "", "Serve the dashboard on this address if present, e.g., :8080")
 	flag.Parse()
 	if *fullNodeUrl == "" || *outputFile == "" {
 		flag.Usage()
@@ -24,30 +26,41 @@ func main() {
 	}
 
 	// catch Ctrl+C
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-	stopCh := make(chan struct{})
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-	blockHashCh, err := clients.SubscribeBlockHash(*fullNodeUrl, stopCh)
+	source := clients.NewFullnodeBlockSource("fullnode-block", *fullNodeUrl)
+	eventCh, err := source.Start(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	jsonCh := make(chan map[string]common.Hash)
-	go func() {
-		// put into a map
-		for blockHash := range blockHashCh {
-			jsonMap := make(map[string]common.Hash)
-			jsonMap["hash"] = blockHash
-			jsonCh <- jsonMap
+	var observe func(clients.Event)
+	if *httpAddr != "" {
+		blockNumber, err := clients.NewBlockNumberOnFullnode(ctx, *fullNodeUrl)
+		if err != nil {
+			log.Fatal(err)
 		}
-		close(jsonCh)
-	}()
+		engine, err := stats.NewEngine(1 << 16)
+		if err != nil {
+			log.Fatal(err)
+		}
+		observe = clients.Observer(engine)
+		go dashboard.NewServer(engine, []clients.Source{source}, blockNumber).ListenAndServe(ctx, *httpAddr)
+	}
 
-	go utils.Run(jsonCh, stopCh, *outputFile)
+	writer, err := utils.Run(clients.Records(eventCh, utils.NewClock(), observe), *outputFile)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	<-signals
-	log.Println("Ctrl+C detected, exiting...")
-	close(stopCh)
-	time.Sleep(1 * time.Second) // give some time for other goroutines to stop
+	select {
+	case <-ctx.Done():
+		log.Println("Ctrl+C detected, exiting...")
+	case <-writer.Done(): // the source stopped by itself
+	}
+	// eventCh is closed after the source is released, then the writer flushes
+	if err := writer.Wait(); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -1,23 +1,27 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/dashboard"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
 	"github.com/crypto-crawler/fullnode-benchmarks/utils"
 	"github.com/ethereum/go-ethereum/common"
 )
 
-// Use PullPairReservesBulk().
+// Use PullPairReservesBulkHeader().
 func main() {
 	fullNodeUrl := flag.String("fullnode", os.Getenv("FULLNODE_URL"), "The fullnode URL")
 	outputFile := flag.String("output", "fullnode-pair-reserve-bulk-header.json", "The output file")
 	pairFile := flag.String("pairs", "pairs.txt.gz", "The pairs file")
+	httpAddr := flag.String("http", "", "Serve the dashboard on this address if present, e.g., :8080")
+	onError := flag.String("on-error", string(clients.ErrorPolicyRetry), "What to do after eth_call failed: retry, skip or abort")
 	flag.Parse()
 	if *fullNodeUrl == "" || *outputFile == "" {
 		flag.Usage()
@@ -25,9 +29,8 @@ func main() {
 	}
 
 	// catch Ctrl+C
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-	stopCh := make(chan struct{})
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
 	pairs := []common.Address{
 		common.HexToAddress("0x58f876857a02d6762e0101bb5c46a8c1ed44dc16"),
@@ -44,15 +47,45 @@ func main() {
 		pairs = arr
 	}
 
-	pairReserveCh, err := clients.PullPairReservesBulkHeader(*fullNodeUrl, pairs, stopCh)
+	policy, err := clients.ParseErrorPolicy(*onError)
 	if err != nil {
 		log.Fatal(err)
 	}
+	source, err := clients.NewFullnodeReserveSource("fullnode-pair-reserve-bulk-header", *fullNodeUrl, pairs, clients.ReserveModeBulkHeader, policy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	eventCh, err := source.Start(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var observe func(clients.Event)
+	if *httpAddr != "" {
+		blockNumber, err := clients.NewBlockNumberOnFullnode(ctx, *fullNodeUrl)
+		if err != nil {
+			log.Fatal(err)
+		}
+		engine, err := stats.NewEngine(1 << 16)
+		if err != nil {
+			log.Fatal(err)
+		}
+		observe = clients.Observer(engine)
+		go dashboard.NewServer(engine, []clients.Source{source}, blockNumber).ListenAndServe(ctx, *httpAddr)
+	}
 
-	go utils.Run(pairReserveCh, stopCh, *outputFile)
+	writer, err := utils.Run(clients.Records(eventCh, utils.NewClock(), observe), *outputFile)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	<-signals
-	log.Println("Ctrl+C detected, exiting...")
-	close(stopCh)
-	time.Sleep(1 * time.Second) // give some time for other goroutines to stop
+	select {
+	case <-ctx.Done():
+		log.Println("Ctrl+C detected, exiting...")
+	case <-writer.Done(): // the source stopped by itself
+	}
+	// eventCh is closed after the source is released, then the writer flushes
+	if err := writer.Wait(); err != nil {
+		log.Fatal(err)
+	}
 }
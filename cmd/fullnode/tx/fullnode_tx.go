@@ -1,53 +1,87 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
 	"os/signal"
+	"runtime"
 	"syscall"
-	"time"
 
 	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/dashboard"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
 	"github.com/crypto-crawler/fullnode-benchmarks/utils"
-	"github.com/ethereum/go-ethereum/common"
 )
 
 // Subscribe to pending transactions from a standard fullnode.
 func main() {
 	fullNodeUrl := flag.String("fullnode", os.Getenv("FULLNODE_URL"), "The fullnode URL")
 	outputFile := flag.String("output", "fullnode-tx.json", "The output file")
+	httpAddr := flag.String("http", "", "Serve the dashboard on this address if present, e.g., :8080")
+	mode := flag.String("mode", "hash", "hash to subscribe to hashes, tx to look up transactions, which are decoded")
+	abiFiles := flag.String("abi", "", "Comma-separated ABI files to decode calls with, in addition to the PancakeSwap router and pair")
+	signatureFiles := flag.String("signatures", "", "Comma-separated files of function signatures, one per line, to name calls with")
+	filterExpr := flag.String("filter", "", "Only keep matching transactions, requires -mode tx, e.g., \"to=0x10ED43C718714eb63d5aA57B78B54704E256024E contract\", see clients.ParseTxFilter()")
 	flag.Parse()
-	if *fullNodeUrl == "" || *outputFile == "" {
+	if *fullNodeUrl == "" || *outputFile == "" || (*mode != "hash" && *mode != "tx") || (*filterExpr != "" && *mode != "tx") {
 		flag.Usage()
 		return
 	}
 
+	registry, err := utils.LoadSelectorRegistry(*abiFiles, *signatureFiles)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var filter *clients.TxFilter
+	if *filterExpr != "" {
+		if filter, err = clients.ParseTxFilter(*filterExpr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// catch Ctrl+C
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-	stopCh := make(chan struct{})
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-	txHashCh, err := clients.SubscribePendingTxHash(*fullNodeUrl, stopCh)
+	source := clients.NewFullnodeTxHashSource("fullnode-tx", *fullNodeUrl)
+	if *mode == "tx" {
+		source = clients.NewFullnodeTxSource("fullnode-tx", *fullNodeUrl, nil, nil, clients.LookupOptions{})
+	}
+	eventCh, err := source.Start(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	eventCh = clients.ApplyTxFilter(eventCh, filter, runtime.NumCPU())
+	eventCh = clients.Decode(eventCh, registry)
 
-	jsonCh := make(chan map[string]common.Hash)
-	go func() {
-		// put into a map
-		for txHash := range txHashCh {
-			jsonMap := make(map[string]common.Hash)
-			jsonMap["hash"] = txHash
-			jsonCh <- jsonMap
+	var observe func(clients.Event)
+	if *httpAddr != "" {
+		blockNumber, err := clients.NewBlockNumberOnFullnode(ctx, *fullNodeUrl)
+		if err != nil {
+			log.Fatal(err)
+		}
+		engine, err := stats.NewEngine(1 << 16)
+		if err != nil {
+			log.Fatal(err)
 		}
-		close(jsonCh)
-	}()
+		observe = clients.Observer(engine)
+		go dashboard.NewServer(engine, []clients.Source{source}, blockNumber).ListenAndServe(ctx, *httpAddr)
+	}
 
-	go utils.Run(jsonCh, stopCh, *outputFile)
+	writer, err := utils.Run(clients.Records(eventCh, utils.NewClock(), observe), *outputFile)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	<-signals
-	log.Println("Ctrl+C detected, exiting...")
-	close(stopCh)
-	time.Sleep(1 * time.Second) // give some time for other goroutines to stop
+	select {
+	case <-ctx.Done():
+		log.Println("Ctrl+C detected, exiting...")
+	case <-writer.Done(): // the source stopped by itself
+	}
+	// eventCh is closed after the source is released, then the writer flushes
+	if err := writer.Wait(); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+)
+
+// Detect transactions which appear in blocks but were never seen pending by
+// any source, i.e., private transactions, from the inclusion file written by
+// cmd/race and the output files of pending transaction sources, e.g.,
+// private -inclusions inclusion.json.gz fullnode-tx.json.gz bloxroute-tx.json.gz
+func main() {
+	inclusionFile := flag.String("inclusions", "inclusion.json", "The inclusion file written by race")
+	blocks := flag.Bool("blocks", true, "Print statistics of every block")
+	excludeOutages := flag.Bool("exclude-outages", true, "Remove transactions pending while any source was reconnecting")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file1 [file2 ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	files := flag.Args()
+	if *inclusionFile == "" || len(files) == 0 {
+		flag.Usage()
+		return
+	}
+
+	inclusions, err := utils.ReadInclusions(*inclusionFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	names := make([]string, len(files))
+	timestamps := make([]map[string]int64, len(files))
+	outages := make([]utils.Outage, 0)
+	for i, file := range files {
+		names[i] = utils.SourceName(file)
+		if timestamps[i], err = utils.ReadTimestamps(file); err != nil {
+			log.Fatal(err)
+		}
+		arr, err := utils.ReadOutages(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		outages = append(outages, arr...)
+	}
+	if *excludeOutages && len(outages) > 0 {
+		kept := utils.ExcludeInclusionOutages(inclusions, timestamps, outages)
+		fmt.Printf("Excluded %d transactions pending during %d outages\n\n", len(inclusions)-len(kept), len(outages))
+		inclusions = kept
+	}
+
+	coverages := stats.DetectPrivate(inclusions, names, timestamps)
+	if *blocks {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(w, "block\ttxs\tprivate\tprivate rate\t%s\t\n", strings.Join(names, "\t"))
+		for _, coverage := range coverages {
+			fmt.Fprintf(w, "%d\t%d\t%d\t%.2f%%\t", coverage.Number, coverage.Txs, len(coverage.Private), coverage.PrivateRate()*100)
+			for _, name := range names {
+				fmt.Fprintf(w, "%d\t", coverage.Seen[name])
+			}
+			fmt.Fprintln(w)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
+	txs, private := 0, 0
+	seen := make(map[string]int)
+	for _, coverage := range coverages {
+		txs += coverage.Txs
+		private += len(coverage.Private)
+		for name, n := range coverage.Seen {
+			seen[name] += n
+		}
+	}
+	fmt.Printf("%d blocks, %d transactions, %d private (%.2f%%)\n\n", len(coverages), txs, private, percent(private, txs))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "file\tseen\tcoverage\t")
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%d\t%.2f%%\t\n", name, seen[name], percent(seen[name], txs))
+	}
+	w.Flush()
+}
+
+func percent(n int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+)
+
+// Feed pending transactions to tracker as well, if it is not nil.
+func observeInclusions(observe func(clients.Event), tracker *stats.InclusionTracker) func(clients.Event) {
+	if tracker == nil {
+		return observe
+	}
+	return func(event clients.Event) {
+		observe(event)
+		if !event.Backfilled {
+			tracker.Observe(event.Source, event.Key, event.ReceivedAt)
+		}
+	}
+}
+
+// Match transactions of new blocks from the fullnode against pending
+// transactions seen by sources, and write inclusions to output.
+func trackInclusions(ctx context.Context, fullNodeUrl string, tracker *stats.InclusionTracker, clock *utils.Clock, output string) (*utils.Writer, error) {
+	sub, err := clients.SubscribeBlockTxHashes(ctx, fullNodeUrl, func(outage clients.Outage) {
+		log.Printf("%s: resubscribed after %v, blocks in between are missing", fullNodeUrl, outage.End.Sub(outage.Start))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recordCh := make(chan map[string]interface{}, 1024)
+	go func() {
+		defer close(recordCh)
+		defer sub.Close()
+		for block := range sub.C {
+			keys := make([]string, len(block.TxHashes))
+			for i, hash := range block.TxHashes {
+				keys[i] = hash.Hex()
+			}
+			for _, inclusion := range tracker.Include(stats.IncludedBlock{
+				Hash:       block.Hash.Hex(),
+				Number:     block.Number,
+				Keys:       keys,
+				IncludedAt: block.NotifiedAt,
+			}) {
+				recordCh <- inclusionRecord(inclusion, clock)
+			}
+		}
+	}()
+	return utils.Run(recordCh, output)
+}
+
+// An inclusion as a JSON record, received_at is when the block was received,
+// private is true if no source saw the transaction pending, see cmd/private.
+func inclusionRecord(inclusion stats.Inclusion, clock *utils.Clock) map[string]interface{} {
+	record := map[string]interface{}{
+		"hash":         inclusion.Key,
+		"block_hash":   inclusion.BlockHash,
+		"block_number": inclusion.BlockNumber,
+		"position":     inclusion.Position,
+		"received_at":  clock.Milli(inclusion.IncludedAt),
+		"deltas":       inclusion.Deltas,
+		"private":      !inclusion.Seen(),
+	}
+	if inclusion.Seen() {
+		record["first_seen"] = clock.Milli(inclusion.FirstSeen)
+		record["first_source"] = inclusion.FirstSource
+	}
+	return record
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/dashboard"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Source types
+const (
+	typeFullnode    = "fullnode"
+	typeBloXroute   = "bloxroute"
+	typeBlocknative = "blocknative"
+)
+
+type SourceConfig struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`             // fullnode, bloxroute or blocknative
+	Output string `json:"output,omitempty"` // defaults to <name>.json
+	// fullnode
+	Url     string `json:"url,omitempty"`
+	Mode    string `json:"mode,omitempty"`     // for reserves: poll, bulk or bulk_header, for transactions: hash or tx
+	OnError string `json:"on_error,omitempty"` // for reserves: retry, skip or abort, see clients.ErrorPolicy
+	// for transactions in the tx mode, see clients.LookupOptions
+	Workers   int    `json:"workers,omitempty"`
+	QueueSize int    `json:"queue_size,omitempty"`
+	BatchSize int    `json:"batch_size,omitempty"`
+	Window    string `json:"window,omitempty"` // e.g., 2ms
+	Drop      string `json:"drop,omitempty"`   // block, newest or oldest
+	// bloXroute, connects to the cloud API if gateway is empty
+	Cert    string `json:"cert,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Gateway string `json:"gateway,omitempty"`
+	Header  string `json:"header,omitempty"`
+	// blocknative, connects to clients.BlocknativeUrl if url is empty
+	ApiKey      string                   `json:"apikey,omitempty"`
+	Filters     []map[string]interface{} `json:"filters,omitempty"`      // see clients.BlocknativeConfig
+	WatchRouter *bool                    `json:"watch_router,omitempty"` // defaults to true on bsc-main
+	// network name of bloXroute (BSC-Mainnet) or blocknative (bsc-main)
+	Network string `json:"network,omitempty"`
+}
+
+type Config struct {
+	Kind  clients.Kind `json:"kind"`            // tx, block or reserve
+	Pairs string       `json:"pairs,omitempty"` // the pairs file for reserves
+	// The fullnode to read the latest block number from for the dashboard,
+	// defaults to the first fullnode source.
+	BlockNumberUrl string `json:"block_number_url,omitempty"`
+	// The fullnode to watch blocks from, for inclusions of pending
+	// transactions seen by sources, only for tx, see stats.InclusionTracker.
+	InclusionUrl    string `json:"inclusion_url,omitempty"`
+	InclusionOutput string `json:"inclusion_output,omitempty"` // defaults to inclusion.json
+	// ABI (.json) or signature list files, transactions are annotated with
+	// the method called, on top of the Uniswap V2 router and pair, only for
+	// tx, see utils.SelectorRegistry.
+	Selectors []string `json:"selectors,omitempty"`
+	// Transactions of all sources are filtered the same way, given as an
+	// object or an expression, only for tx, see clients.TxFilter.
+	Filter  *clients.TxFilter `json:"filter,omitempty"`
+	Sources []SourceConfig    `json:"sources"`
+}
+
+func (c *Config) blockNumberUrl() string {
+	if c.BlockNumberUrl != "" {
+		return c.BlockNumberUrl
+	}
+	for _, source := range c.Sources {
+		if source.Type == typeFullnode {
+			return source.Url
+		}
+	}
+	return ""
+}
+
+func readConfig(file string) (*Config, error) {
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := json.Unmarshal(bytes, config); err != nil {
+		return nil, err
+	}
+
+	if config.Kind != clients.KindTx && config.Kind != clients.KindBlock && config.Kind != clients.KindReserve {
+		return nil, fmt.Errorf("invalid kind: %s", config.Kind)
+	}
+	if len(config.Sources) == 0 {
+		return nil, fmt.Errorf("no sources in %s", file)
+	}
+	if config.InclusionUrl != "" && config.Kind != clients.KindTx {
+		return nil, fmt.Errorf("inclusion_url is only for %s", clients.KindTx)
+	}
+	if len(config.Selectors) > 0 && config.Kind != clients.KindTx {
+		return nil, fmt.Errorf("selectors is only for %s", clients.KindTx)
+	}
+	if config.Filter != nil && config.Kind != clients.KindTx {
+		return nil, fmt.Errorf("filter is only for %s", clients.KindTx)
+	}
+	if config.InclusionOutput == "" {
+		config.InclusionOutput = "inclusion.json"
+	}
+	names := make(map[string]bool)
+	for i := range config.Sources {
+		source := &config.Sources[i]
+		if source.Name == "" {
+			return nil, fmt.Errorf("the name of source %d is empty", i)
+		}
+		if names[source.Name] {
+			return nil, fmt.Errorf("duplicated source name: %s", source.Name)
+		}
+		names[source.Name] = true
+		if source.Output == "" {
+			source.Output = source.Name + ".json"
+		}
+		if config.Filter != nil && source.Type == typeFullnode && source.Mode != "tx" {
+			return nil, fmt.Errorf("%s: filter requires the tx mode", source.Name)
+		}
+	}
+	return config, nil
+}
+
+// Subscribe to the same stream from multiple sources in one process, e.g.,
+// race -config race.config.example.json
+//
+// All sources share one clock, so received_at timestamps are free of the
+// skew between processes. Each source writes to its own output file, which
+// can be compared by cmd/compare.
+func main() {
+	configFile := flag.String("config", "race.config.json", "The config file")
+	reportInterval := flag.Duration("report", time.Minute, "How often to log the latest statistics, 0 to disable")
+	capacity := flag.Int("capacity", 1<<20, "How many recent keys to keep for matching across sources")
+	httpAddr := flag.String("http", "", "Serve the dashboard on this address if present, e.g., :8080")
+	flag.Parse()
+	if *configFile == "" {
+		flag.Usage()
+		return
+	}
+
+	config, err := readConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pairs := make([]common.Address, 0)
+	if config.Kind == clients.KindReserve {
+		if config.Pairs == "" {
+			log.Fatal("pairs is required for reserves")
+		}
+		pairs, err = utils.ReadPairs(config.Pairs)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var registry *utils.SelectorRegistry
+	if config.Kind == clients.KindTx {
+		registry = utils.DefaultSelectorRegistry()
+		for _, file := range config.Selectors {
+			if err := registry.LoadFile(file); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	sources := make([]clients.Source, 0, len(config.Sources))
+	for _, sourceConfig := range config.Sources {
+		source, err := newSource(config.Kind, sourceConfig, pairs, config.Filter)
+		if err != nil {
+			log.Fatalf("%s: %v", sourceConfig.Name, err)
+		}
+		sources = append(sources, source)
+	}
+
+	// catch Ctrl+C
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	clock := utils.NewClock()
+	engine, err := stats.NewEngine(*capacity)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, source := range sources {
+		engine.AddSource(source.Name())
+	}
+	var tracker *stats.InclusionTracker
+	if config.InclusionUrl != "" {
+		if tracker, err = stats.NewInclusionTracker(*capacity); err != nil {
+			log.Fatal(err)
+		}
+	}
+	writers := make([]*utils.Writer, 0, len(sources)+1)
+	outputs := make([]string, 0, len(sources)+1)
+	for i, source := range sources {
+		eventCh, err := source.Start(ctx)
+		if err != nil {
+			log.Fatalf("%s: %v", source.Name(), err)
+		}
+		eventCh = clients.ApplyTxFilter(eventCh, config.Filter, runtime.NumCPU())
+		if registry != nil {
+			eventCh = clients.Decode(eventCh, registry)
+		}
+		output := config.Sources[i].Output
+		log.Printf("Subscribed to %s, writing to %s", source.Name(), output)
+		observe := observeInclusions(clients.Observer(engine), tracker)
+		writer, err := utils.Run(clients.Records(eventCh, clock, observe), output)
+		if err != nil {
+			log.Fatalf("%s: %v", output, err)
+		}
+		writers = append(writers, writer)
+		outputs = append(outputs, output)
+	}
+	if tracker != nil {
+		writer, err := trackInclusions(ctx, config.InclusionUrl, tracker, clock, config.InclusionOutput)
+		if err != nil {
+			log.Fatalf("%s: %v", config.InclusionUrl, err)
+		}
+		log.Printf("Watching blocks from %s, writing inclusions to %s", config.InclusionUrl, config.InclusionOutput)
+		writers = append(writers, writer)
+		outputs = append(outputs, config.InclusionOutput)
+	}
+	if *reportInterval > 0 {
+		go report(ctx, engine, *reportInterval)
+	}
+	if *httpAddr != "" {
+		var blockNumber dashboard.BlockNumberGetter
+		if url := config.blockNumberUrl(); url != "" {
+			blockNumber, err = clients.NewBlockNumberOnFullnode(ctx, url)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		go dashboard.NewServer(engine, sources, blockNumber).ListenAndServe(ctx, *httpAddr)
+	}
+
+	<-ctx.Done()
+	log.Println("Ctrl+C detected, exiting...")
+	// every writer flushes after its source is released
+	for i, writer := range writers {
+		if err := writer.Wait(); err != nil {
+			log.Printf("%s: %v", outputs[i], err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Create a source from its config, filter is passed to sources which can
+// filter transactions on the server.
+func newSource(kind clients.Kind, config SourceConfig, pairs []common.Address, filter *clients.TxFilter) (clients.Source, error) {
+	switch config.Type {
+	case typeFullnode:
+		if config.Url == "" {
+			return nil, fmt.Errorf("url is required for %s", config.Type)
+		}
+		switch kind {
+		case clients.KindTx:
+			switch config.Mode {
+			case "", "hash":
+				return clients.NewFullnodeTxHashSource(config.Name, config.Url), nil
+			case "tx":
+				options := clients.LookupOptions{
+					Workers:   config.Workers,
+					QueueSize: config.QueueSize,
+					BatchSize: config.BatchSize,
+				}
+				var err error
+				if config.Window != "" {
+					if options.Window, err = time.ParseDuration(config.Window); err != nil {
+						return nil, err
+					}
+				}
+				if config.Drop != "" {
+					if options.Drop, err = clients.ParseDropPolicy(config.Drop); err != nil {
+						return nil, err
+					}
+				}
+				return clients.NewFullnodeTxSource(config.Name, config.Url, nil, nil, options), nil
+			default:
+				return nil, fmt.Errorf("invalid tx mode: %s", config.Mode)
+			}
+		case clients.KindBlock:
+			return clients.NewFullnodeBlockSource(config.Name, config.Url), nil
+		case clients.KindReserve:
+			mode := clients.ReserveMode(config.Mode)
+			if mode == "" {
+				mode = clients.ReserveModePoll
+			}
+			policy := clients.ErrorPolicyRetry
+			if config.OnError != "" {
+				var err error
+				if policy, err = clients.ParseErrorPolicy(config.OnError); err != nil {
+					return nil, err
+				}
+			}
+			return clients.NewFullnodeReserveSource(config.Name, config.Url, pairs, mode, policy)
+		}
+	case typeBloXroute:
+		bloXrouteConfig := clients.BloXrouteConfig{
+			Network: config.Network,
+			Cert:    config.Cert,
+			Key:     config.Key,
+			Gateway: config.Gateway,
+			Header:  config.Header,
+		}
+		switch kind {
+		case clients.KindTx:
+			return clients.NewBloXrouteTxSource(config.Name, bloXrouteConfig, filter), nil
+		case clients.KindBlock:
+			return clients.NewBloXrouteBlockSource(config.Name, bloXrouteConfig), nil
+		case clients.KindReserve:
+			return clients.NewBloXrouteReserveSource(config.Name, bloXrouteConfig, pairs), nil
+		}
+	case typeBlocknative:
+		if config.ApiKey == "" {
+			return nil, fmt.Errorf("apikey is required for %s", config.Type)
+		}
+		blocknativeConfig := clients.BlocknativeConfig{
+			ApiKey:  config.ApiKey,
+			Url:     config.Url,
+			Network: config.Network,
+			Filters: config.Filters,
+		}
+		if blocknativeConfig.Network == "" {
+			blocknativeConfig.Network = "bsc-main"
+		}
+		if config.WatchRouter != nil {
+			blocknativeConfig.WatchRouter = *config.WatchRouter
+		} else {
+			blocknativeConfig.WatchRouter = blocknativeConfig.Network == "bsc-main"
+		}
+		switch kind {
+		case clients.KindTx:
+			return clients.NewBlocknativeTxSource(config.Name, blocknativeConfig), nil
+		case clients.KindBlock:
+			return clients.NewBlocknativeBlockSource(config.Name, blocknativeConfig), nil
+		}
+	default:
+		return nil, fmt.Errorf("invalid source type: %s", config.Type)
+	}
+	return nil, fmt.Errorf("%s does not support %s", config.Type, kind)
+}
+
+// Log the latest statistics periodically.
+func report(ctx context.Context, engine *stats.Engine, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			snapshot := engine.Snapshot(0.05, 0.95)
+			for _, source := range snapshot.Sources {
+				log.Printf("%s: seen %d, win rate %.2f%%, coverage %.2f%%",
+					source.Name, source.Seen, source.WinRate*100, source.Coverage*100)
+			}
+			for _, pair := range snapshot.Pairs {
+				log.Printf("%s - %s: count %d, mean %.2fms, p50 %.1fms, p90 %.1fms, p99 %.1fms",
+					pair.Source1, pair.Source2, pair.Gaps.Count, pair.Gaps.Mean, pair.Gaps.P50, pair.Gaps.P90, pair.Gaps.P99)
+			}
+		}
+	}
+}
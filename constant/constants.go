@@ -5,8 +5,9 @@ import (
 )
 
 const (
-	BSC_TESTNET_CHAIN_ID int64 = 97
-	BSC_MAINNET_CHAIN_ID int64 = 56
+	ETHEREUM_MAINNET_CHAIN_ID int64 = 1
+	BSC_TESTNET_CHAIN_ID      int64 = 97
+	BSC_MAINNET_CHAIN_ID      int64 = 56
 )
 
 var (
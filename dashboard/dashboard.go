@@ -0,0 +1,162 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
+)
+
+// Anything that knows the latest block number, e.g., *clients.BlockNumber.
+type BlockNumberGetter interface {
+	Get() *big.Int
+}
+
+// Live statistics of a running benchmark, served as JSON at /stats.json and
+// as a HTML page at /, along with Prometheus metrics at /metrics.
+type Server struct {
+	start       time.Time
+	engine      *stats.Engine
+	sources     []clients.Source
+	blockNumber BlockNumberGetter // optional
+	mux         *http.ServeMux
+}
+
+func NewServer(engine *stats.Engine, sources []clients.Source, blockNumber BlockNumberGetter) *Server {
+	s := &Server{
+		start:       time.Now(),
+		engine:      engine,
+		sources:     sources,
+		blockNumber: blockNumber,
+		mux:         http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/stats.json", s.serveJSON)
+	s.mux.Handle("/metrics", metrics.Handler())
+	s.mux.HandleFunc("/", s.serveHTML)
+	return s
+}
+
+type SourceStatus struct {
+	Name string       `json:"name"`
+	Kind clients.Kind `json:"kind"`
+	clients.SourceStats
+	Seen       uint64  `json:"seen"`
+	Backfilled uint64  `json:"backfilled"`
+	Wins       uint64  `json:"wins"`
+	WinRate    float64 `json:"win_rate"`
+	Coverage   float64 `json:"coverage"`
+}
+
+type Status struct {
+	Uptime      float64              `json:"uptime"` // seconds
+	BlockNumber *uint64              `json:"block_number,omitempty"`
+	Keys        uint64               `json:"keys"`
+	Sources     []SourceStatus       `json:"sources"`
+	Pairs       []stats.PairSnapshot `json:"pairs"`
+}
+
+// Current status, with gaps trimmed at the 5% and 95% quantiles.
+func (s *Server) Status() Status {
+	snapshot := s.engine.Snapshot(0.05, 0.95)
+	status := Status{
+		Uptime:  time.Since(s.start).Seconds(),
+		Keys:    snapshot.Keys,
+		Sources: make([]SourceStatus, 0, len(s.sources)),
+		Pairs:   snapshot.Pairs,
+	}
+	if s.blockNumber != nil {
+		number := s.blockNumber.Get().Uint64()
+		status.BlockNumber = &number
+	}
+
+	bySource := make(map[string]stats.SourceSnapshot)
+	for _, source := range snapshot.Sources {
+		bySource[source.Name] = source
+	}
+	for _, source := range s.sources {
+		snapshot := bySource[source.Name()]
+		status.Sources = append(status.Sources, SourceStatus{
+			Name:        source.Name(),
+			Kind:        source.Kind(),
+			SourceStats: source.Stats(),
+			Seen:        snapshot.Seen,
+			Backfilled:  snapshot.Backfilled,
+			Wins:        snapshot.Wins,
+			WinRate:     snapshot.WinRate,
+			Coverage:    snapshot.Coverage,
+		})
+	}
+	return status
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) serveJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Status()); err != nil {
+		log.Println(err)
+	}
+}
+
+func (s *Server) serveHTML(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, s.Status()); err != nil {
+		log.Println(err)
+	}
+}
+
+// Serve the dashboard on addr, e.g., :8080, until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) {
+	httpServer := &http.Server{Addr: addr, Handler: s}
+	go func() {
+		<-ctx.Done()
+		httpServer.Close()
+	}()
+	log.Printf("Serving dashboard on %s", addr)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Println(err)
+	}
+}
+
+var page = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"percent": func(x float64) string { return fmt.Sprintf("%.2f%%", x*100) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>fullnode-benchmarks</title>
+<style>
+body { font-family: monospace; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: right; }
+</style>
+</head>
+<body>
+<p>uptime {{printf "%.0f" .Uptime}}s{{if .BlockNumber}}, block {{.BlockNumber}}{{end}}, {{.Keys}} keys</p>
+<table>
+<tr><th>source</th><th>kind</th><th>received</th><th>errors</th><th>reconnects</th><th>backfilled</th><th>win rate</th><th>coverage</th></tr>
+{{range .Sources}}<tr><td>{{.Name}}</td><td>{{.Kind}}</td><td>{{.Received}}</td><td>{{.Errors}}</td><td>{{.Reconnects}}</td><td>{{.Backfilled}}</td><td>{{percent .WinRate}}</td><td>{{percent .Coverage}}</td></tr>
+{{end}}</table>
+<table>
+<tr><th>source1</th><th>source2</th><th>count</th><th>mean</th><th>p50</th><th>p90</th><th>p99</th></tr>
+{{range .Pairs}}<tr><td>{{.Source1}}</td><td>{{.Source2}}</td><td>{{.Gaps.Count}}</td><td>{{printf "%.2f" .Gaps.Mean}}</td><td>{{printf "%.1f" .Gaps.P50}}</td><td>{{printf "%.1f" .Gaps.P90}}</td><td>{{printf "%.1f" .Gaps.P99}}</td></tr>
+{{end}}</table>
+<p>gaps in milliseconds trimmed at the 5% and 95% quantiles, a negative gap means source1 is faster, see <a href="/stats.json">stats.json</a></p>
+</body>
+</html>
+`))
@@ -0,0 +1,96 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/clients"
+	"github.com/crypto-crawler/fullnode-benchmarks/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSource struct {
+	name  string
+	stats clients.SourceStats
+}
+
+func (s *fakeSource) Name() string       { return s.name }
+func (s *fakeSource) Kind() clients.Kind { return clients.KindBlock }
+func (s *fakeSource) Start(ctx context.Context) (<-chan clients.Event, error) {
+	return nil, nil
+}
+func (s *fakeSource) Stats() clients.SourceStats { return s.stats }
+
+type fakeBlockNumber int64
+
+func (n fakeBlockNumber) Get() *big.Int { return big.NewInt(int64(n)) }
+
+func newTestServer() *httptest.Server {
+	sources := []clients.Source{
+		&fakeSource{name: "fullnode", stats: clients.SourceStats{Received: 4, Reconnects: 1}},
+		&fakeSource{name: "bloxroute", stats: clients.SourceStats{Received: 4}},
+	}
+	engine, err := stats.NewEngine(16)
+	if err != nil {
+		panic(err)
+	}
+	start := time.Now()
+	for _, key := range []string{"0x01", "0x02", "0x03"} {
+		engine.Observe("bloxroute", key, start)
+		engine.Observe("fullnode", key, start.Add(50*time.Millisecond))
+	}
+	engine.Observe("fullnode", "0x04", start)
+	engine.Observe("bloxroute", "0x04", start.Add(50*time.Millisecond))
+
+	return httptest.NewServer(NewServer(engine, sources, fakeBlockNumber(16448132)))
+}
+
+func TestStatusJSON(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stats.json")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	status := Status{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	assert.Equal(t, uint64(16448132), *status.BlockNumber)
+	assert.Equal(t, uint64(4), status.Keys)
+	assert.Equal(t, 2, len(status.Sources))
+	assert.Equal(t, "fullnode", status.Sources[0].Name)
+	assert.Equal(t, uint64(1), status.Sources[0].Reconnects)
+	assert.Equal(t, 0.25, status.Sources[0].WinRate)
+	assert.Equal(t, 1.0, status.Sources[1].Coverage)
+
+	assert.Equal(t, 1, len(status.Pairs))
+	assert.Equal(t, "bloxroute", status.Pairs[0].Source1)
+	assert.Equal(t, 3, status.Pairs[0].Gaps.Count) // +50ms is trimmed
+	assert.InDelta(t, -50, status.Pairs[0].Gaps.Mean, 0.1)
+}
+
+func TestStatusHTML(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(body), "block 16448132"))
+	assert.True(t, strings.Contains(string(body), "<td>fullnode</td>"))
+
+	resp, err = http.Get(server.URL + "/missing")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
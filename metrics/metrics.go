@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "fullnode_benchmarks"
+
+var (
+	// Messages received by each source.
+	MessagesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_received_total",
+		Help:      "Number of messages received by each source.",
+	}, []string{"source"})
+
+	// Duplicated records dropped by the `visited` maps of polling loops.
+	DedupHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dedup_hits_total",
+		Help:      "Number of duplicated records dropped by polling loops.",
+	}, []string{"feed"})
+
+	// Failed JSON-RPC requests.
+	RPCErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_errors_total",
+		Help:      "Number of failed JSON-RPC requests.",
+	}, []string{"method"})
+
+	// Messages of websocket feeds which could not be decoded.
+	DecodeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "decode_errors_total",
+		Help:      "Number of messages of websocket feeds which could not be decoded.",
+	}, []string{"source"})
+
+	// Records dropped because a bounded queue was full.
+	Drops = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "drops_total",
+		Help:      "Number of records dropped because a bounded queue was full.",
+	}, []string{"queue"})
+
+	// Reconnections of websocket feeds.
+	Reconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconnects_total",
+		Help:      "Number of reconnections of websocket feeds.",
+	}, []string{"source"})
+
+	// Lines waiting to be written by utils.Run().
+	WriterQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "writer_queue_depth",
+		Help:      "Number of lines waiting in the queue of the output writer.",
+	}, []string{"output"})
+
+	// Time spent flushing the output file.
+	FlushDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "flush_duration_seconds",
+		Help:      "Time spent flushing the output file.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8), // 0.1ms to 1.6s
+	}, []string{"output"})
+)
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		MessagesReceived,
+		DedupHits,
+		RPCErrors,
+		DecodeErrors,
+		Drops,
+		Reconnects,
+		WriterQueueDepth,
+		FlushDuration,
+	)
+}
+
+// Handler serves all metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
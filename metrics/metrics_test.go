@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	MessagesReceived.WithLabelValues("fullnode-tx").Add(3)
+	RPCErrors.WithLabelValues("eth_getTransactionByHash").Inc()
+	FlushDuration.WithLabelValues("fullnode-tx.json").Observe(0.001)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(recorder.Result().Body)
+	assert.NoError(t, err)
+
+	text := string(body)
+	assert.True(t, strings.Contains(text, `fullnode_benchmarks_messages_received_total{source="fullnode-tx"} 3`))
+	assert.True(t, strings.Contains(text, `fullnode_benchmarks_rpc_errors_total{method="eth_getTransactionByHash"} 1`))
+	assert.True(t, strings.Contains(text, `fullnode_benchmarks_flush_duration_seconds_count{output="fullnode-tx.json"} 1`))
+	assert.True(t, strings.Contains(text, "go_goroutines"))
+}
@@ -0,0 +1,28 @@
+package pojo
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A block header received from a fullnode.
+type BlockHeader struct {
+	Hash   common.Hash `json:"hash"`
+	Number uint64      `json:"number"`
+	// Fetched after a gap in block numbers was detected, rather than pushed
+	// by the subscription, so the received_at timestamp is not a latency.
+	Backfilled bool `json:"backfilled,omitempty"`
+}
+
+func (b *BlockHeader) IsBackfilled() bool {
+	return b.Backfilled
+}
+
+// A block with the hashes of its transactions in order.
+type BlockTxHashes struct {
+	Hash       common.Hash   `json:"hash"`
+	Number     uint64        `json:"number"`
+	TxHashes   []common.Hash `json:"transactions"`
+	NotifiedAt time.Time     `json:"-"` // when the header was notified
+}
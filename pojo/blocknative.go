@@ -1,11 +1,13 @@
 package pojo
 
 import (
-	"encoding/hex"
+	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 )
 
 // ConnectResponse is the message we receive when opening a connection to the API
@@ -68,31 +70,67 @@ type BlocknativeMsg struct {
 			WatchedAddress   string    `json:"watchedAddress"`
 			Direction        string    `json:"direction"`
 			Counterparty     string    `json:"counterparty"`
+
+			// EIP-2718 and EIP-1559
+			Type                 int              `json:"type,omitempty"`
+			MaxFeePerGas         string           `json:"maxFeePerGas,omitempty"`
+			MaxPriorityFeePerGas string           `json:"maxPriorityFeePerGas,omitempty"`
+			AccessList           types.AccessList `json:"accessList,omitempty"`
+			// the signature, which is absent in some events
+			V *hexutil.Big `json:"v,omitempty"`
+			R *hexutil.Big `json:"r,omitempty"`
+			S *hexutil.Big `json:"s,omitempty"`
 		} `json:"transaction"`
 	} `json:"event"`
 }
 
+// Chain IDs of Blocknative networks.
+var blocknativeChainIDs = map[string]int64{
+	"main":       1,
+	"ropsten":    3,
+	"rinkeby":    4,
+	"goerli":     5,
+	"kovan":      42,
+	"bsc-main":   56,
+	"xdai":       100,
+	"matic-main": 137,
+}
+
 // Implement the TxData interface for BlocknativeMsg.
 
+// Nil if input is invalid, see DecodeInput().
 func (tx *BlocknativeMsg) Data() []byte {
-	data, _ := hex.DecodeString(tx.Event.Transaction.Input[2:])
+	data, _ := tx.DecodeInput()
 	return data
 }
 
+// DecodeInput decodes the call data, which is nil if absent, an invalid
+// input doesn't fail decoding the whole message.
+func (tx *BlocknativeMsg) DecodeInput() ([]byte, error) {
+	if tx.Event.Transaction.Input == "" {
+		return nil, nil
+	}
+	data, err := hexutil.Decode(tx.Event.Transaction.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input of %s: %w", tx.Event.Transaction.Hash, err)
+	}
+	return data, nil
+}
+
 func (tx *BlocknativeMsg) Gas() uint64 {
 	return uint64(tx.Event.Transaction.Gas)
 }
 
+// The fee cap of EIP-1559 transactions without gasPrice, zero if absent.
 func (tx *BlocknativeMsg) GasPrice() *big.Int {
-	gasPrice := big.NewInt(0)
-	gasPrice.SetString(tx.Event.Transaction.GasPrice, 0)
-	return gasPrice
+	if tx.Event.Transaction.GasPrice == "" && tx.Event.Transaction.Type == types.DynamicFeeTxType {
+		return parseBigInt(tx.Event.Transaction.MaxFeePerGas)
+	}
+	return parseBigInt(tx.Event.Transaction.GasPrice)
 }
 
 func (tx *BlocknativeMsg) Value() *big.Int {
-	amount := big.NewInt(0)
-	amount.SetString(tx.Event.Transaction.Value, 0)
-	return amount
+	return parseBigInt(tx.Event.Transaction.Value)
 }
 
 func (tx *BlocknativeMsg) Nonce() uint64 {
@@ -100,11 +138,17 @@ func (tx *BlocknativeMsg) Nonce() uint64 {
 }
 
 func (tx *BlocknativeMsg) To() *common.Address {
+	if tx.Event.Transaction.To == "" {
+		return nil
+	}
 	address := common.HexToAddress(tx.Event.Transaction.To)
 	return &address
 }
 
 func (tx *BlocknativeMsg) From() *common.Address {
+	if tx.Event.Transaction.From == "" {
+		return nil
+	}
 	address := common.HexToAddress(tx.Event.Transaction.From)
 	return &address
 }
@@ -116,3 +160,129 @@ func (tx *BlocknativeMsg) Hash() common.Hash {
 func (tx *BlocknativeMsg) Source() string {
 	return "blocknative"
 }
+
+func (tx *BlocknativeMsg) Type() uint8 {
+	return uint8(tx.Event.Transaction.Type)
+}
+
+// The gas price of transactions before EIP-1559, zero if absent.
+func (tx *BlocknativeMsg) GasTipCap() *big.Int {
+	if tx.Event.Transaction.Type != types.DynamicFeeTxType {
+		return parseBigInt(tx.Event.Transaction.GasPrice)
+	}
+	return parseBigInt(tx.Event.Transaction.MaxPriorityFeePerGas)
+}
+
+// The gas price of transactions before EIP-1559, zero if absent.
+func (tx *BlocknativeMsg) GasFeeCap() *big.Int {
+	if tx.Event.Transaction.Type != types.DynamicFeeTxType {
+		return parseBigInt(tx.Event.Transaction.GasPrice)
+	}
+	return parseBigInt(tx.Event.Transaction.MaxFeePerGas)
+}
+
+// Derived from the signature if present, otherwise the chain of the
+// network.
+func (tx *BlocknativeMsg) ChainID() *big.Int {
+	if rebuilt, err := tx.Transaction(); err == nil {
+		return rebuilt.ChainId()
+	}
+	if chainID, ok := blocknativeChainIDs[tx.Event.Blockchain.Network]; ok {
+		return big.NewInt(chainID)
+	}
+	return nil
+}
+
+func (tx *BlocknativeMsg) AccessList() types.AccessList {
+	return tx.Event.Transaction.AccessList
+}
+
+func (tx *BlocknativeMsg) MarshalBinary() ([]byte, error) {
+	rebuilt, err := tx.Transaction()
+	if err != nil {
+		return nil, err
+	}
+	return rebuilt.MarshalBinary()
+}
+
+// Transaction rebuilds the signed transaction, ErrNoSignature if the
+// signature is absent.
+func (tx *BlocknativeMsg) Transaction() (*types.Transaction, error) {
+	transaction := &tx.Event.Transaction
+	if transaction.V == nil || transaction.R == nil || transaction.S == nil {
+		return nil, ErrNoSignature
+	}
+	v, r, s := transaction.V.ToInt(), transaction.R.ToInt(), transaction.S.ToInt()
+	data, err := tx.DecodeInput()
+	if err != nil {
+		return nil, err
+	}
+
+	var inner types.TxData
+	switch transaction.Type {
+	case types.LegacyTxType:
+		inner = &types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: tx.GasPrice(),
+			Gas:      tx.Gas(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     data,
+			V:        v,
+			R:        r,
+			S:        s,
+		}
+	case types.AccessListTxType, types.DynamicFeeTxType:
+		chainID, ok := blocknativeChainIDs[tx.Event.Blockchain.Network]
+		if !ok {
+			return nil, fmt.Errorf("unknown network: %s", tx.Event.Blockchain.Network)
+		}
+		if transaction.Type == types.AccessListTxType {
+			inner = &types.AccessListTx{
+				ChainID:    big.NewInt(chainID),
+				Nonce:      tx.Nonce(),
+				GasPrice:   tx.GasPrice(),
+				Gas:        tx.Gas(),
+				To:         tx.To(),
+				Value:      tx.Value(),
+				Data:       data,
+				AccessList: tx.AccessList(),
+				V:          v,
+				R:          r,
+				S:          s,
+			}
+		} else {
+			inner = &types.DynamicFeeTx{
+				ChainID:    big.NewInt(chainID),
+				Nonce:      tx.Nonce(),
+				GasTipCap:  tx.GasTipCap(),
+				GasFeeCap:  tx.GasFeeCap(),
+				Gas:        tx.Gas(),
+				To:         tx.To(),
+				Value:      tx.Value(),
+				Data:       data,
+				AccessList: tx.AccessList(),
+				V:          v,
+				R:          r,
+				S:          s,
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown transaction type: %d", transaction.Type)
+	}
+
+	rebuilt := types.NewTx(inner)
+	if rebuilt.Hash() != tx.Hash() {
+		return nil, fmt.Errorf("the hash of the rebuilt transaction %s doesn't match %s", rebuilt.Hash(), tx.Hash())
+	}
+	return rebuilt, nil
+}
+
+// Parse a decimal or 0x-prefixed hex integer, zero if invalid.
+func parseBigInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		return big.NewInt(0)
+	}
+	return n
+}
@@ -0,0 +1,52 @@
+package pojo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	bloXrouteTypes "github.com/crypto-crawler/bloxroute-go/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// A bloXroute transaction decoded from raw_tx, or tx_contents if raw_tx is
+// absent, which implements the TxData interface and is written as the
+// original message.
+type BloXrouteTx struct {
+	*RawTransaction
+	msg *bloXrouteTypes.Transaction
+}
+
+func NewBloXrouteTx(msg *bloXrouteTypes.Transaction) (*BloXrouteTx, error) {
+	tx := new(types.Transaction)
+	switch {
+	case msg.RawTx != "":
+		if err := tx.UnmarshalBinary(common.FromHex(msg.RawTx)); err != nil {
+			return nil, err
+		}
+	case msg.TxContents != nil:
+		// the same fields as eth_getTransactionByHash, but typed
+		// transactions lack chainId and are rejected
+		data, err := json.Marshal(msg.TxContents)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("both raw_tx and tx_contents are absent")
+	}
+	if msg.TxHash != "" && tx.Hash() != common.HexToHash(msg.TxHash) {
+		return nil, fmt.Errorf("the hash of the decoded transaction %s doesn't match %s", tx.Hash(), msg.TxHash)
+	}
+	return &BloXrouteTx{
+		RawTransaction: NewRawTransaction(tx, "bloxroute"),
+		msg:            msg,
+	}, nil
+}
+
+func (tx *BloXrouteTx) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tx.msg)
+}
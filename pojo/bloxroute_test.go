@@ -0,0 +1,59 @@
+package pojo
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	bloXrouteTypes "github.com/crypto-crawler/bloxroute-go/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBloXrouteTx(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	to := common.HexToAddress("0x01")
+	tx := types.MustSignNewTx(key, types.LatestSignerForChainID(big.NewInt(56)), &types.LegacyTx{Nonce: 1, To: &to, Value: big.NewInt(2), Gas: 21000, GasPrice: big.NewInt(5e9)})
+	raw, _ := tx.MarshalBinary()
+	msg := &bloXrouteTypes.Transaction{TxHash: tx.Hash().Hex(), RawTx: hexutil.Encode(raw)[2:]}
+
+	decoded, err := NewBloXrouteTx(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, tx.Hash(), decoded.Hash())
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), *decoded.From())
+	assert.Equal(t, "bloxroute", decoded.Source())
+	// written as received
+	expected, _ := json.Marshal(msg)
+	actual, err := json.Marshal(decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	// from tx_contents
+	v, r, s := tx.RawSignatureValues()
+	decoded, err = NewBloXrouteTx(&bloXrouteTypes.Transaction{TxHash: tx.Hash().Hex(), TxContents: &bloXrouteTypes.TxContents{
+		From:     crypto.PubkeyToAddress(key.PublicKey).Hex(),
+		Gas:      hexutil.EncodeUint64(tx.Gas()),
+		GasPrice: hexutil.EncodeBig(tx.GasPrice()),
+		Hash:     tx.Hash().Hex(),
+		Input:    "0x",
+		Nonce:    hexutil.EncodeUint64(tx.Nonce()),
+		To:       to.Hex(),
+		Type:     "0x0",
+		Value:    hexutil.EncodeBig(tx.Value()),
+		V:        hexutil.EncodeBig(v),
+		R:        hexutil.EncodeBig(r),
+		S:        hexutil.EncodeBig(s),
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, tx.Hash(), decoded.Hash())
+
+	_, err = NewBloXrouteTx(&bloXrouteTypes.Transaction{TxHash: tx.Hash().Hex()})
+	assert.Error(t, err)
+	_, err = NewBloXrouteTx(&bloXrouteTypes.Transaction{TxHash: "0x01", RawTx: msg.RawTx})
+	assert.Error(t, err)
+	_, err = NewBloXrouteTx(&bloXrouteTypes.Transaction{TxHash: tx.Hash().Hex(), RawTx: "0x01"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package pojo
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	signersMtx sync.RWMutex
+	// Signers configured by SetSigner(), by chain ID.
+	signers = make(map[uint64]types.Signer)
+)
+
+// SignerForChainID returns the signer to recover senders of a chain, which
+// is types.LatestSignerForChainID() unless configured by SetSigner().
+func SignerForChainID(chainID *big.Int) types.Signer {
+	if chainID != nil && chainID.IsUint64() {
+		signersMtx.RLock()
+		signer, ok := signers[chainID.Uint64()]
+		signersMtx.RUnlock()
+		if ok {
+			return signer
+		}
+	}
+	return types.LatestSignerForChainID(chainID)
+}
+
+// SetSigner configures the signer of a chain, e.g., to reject transaction
+// types a chain doesn't accept, a nil signer restores the default. Senders
+// recovered already are kept.
+func SetSigner(chainID *big.Int, signer types.Signer) error {
+	if chainID == nil || !chainID.IsUint64() {
+		return errors.New("invalid chain ID")
+	}
+	signersMtx.Lock()
+	defer signersMtx.Unlock()
+	if signer == nil {
+		delete(signers, chainID.Uint64())
+	} else {
+		signers[chainID.Uint64()] = signer
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package pojo
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/constant"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSignedTx(key *ecdsa.PrivateKey, chainID *big.Int, nonce uint64, inner types.TxData) *types.Transaction {
+	if inner == nil {
+		to := common.HexToAddress("0x01")
+		inner = &types.LegacyTx{Nonce: nonce, GasPrice: big.NewInt(5e9), Gas: 21000, To: &to, Value: big.NewInt(1)}
+	}
+	return types.MustSignNewTx(key, types.LatestSignerForChainID(chainID), inner)
+}
+
+func TestSignerForChainID(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	bsc := big.NewInt(constant.BSC_MAINNET_CHAIN_ID)
+	dynamicFeeTx := newSignedTx(key, bsc, 0, &types.DynamicFeeTx{ChainID: bsc, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1), Gas: 21000})
+
+	// BSC accepts EIP-1559 transactions since the Hertz fork
+	assert.Equal(t, &sender, NewRawTransaction(dynamicFeeTx, "test").From())
+	assert.Equal(t, &sender, NewRawTransaction(newSignedTx(key, bsc, 0, nil), "test").From())
+
+	// chains without configured signers
+	assert.Equal(t, types.LatestSignerForChainID(big.NewInt(10)), SignerForChainID(big.NewInt(10)))
+	assert.Equal(t, &sender, NewRawTransaction(newSignedTx(key, big.NewInt(10), 0, nil), "test").From())
+
+	// a signer which rejects EIP-1559 transactions, then the default again
+	chainID := big.NewInt(10)
+	dynamicFeeTx = newSignedTx(key, chainID, 0, &types.DynamicFeeTx{ChainID: chainID, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1), Gas: 21000})
+	assert.NoError(t, SetSigner(chainID, types.NewEIP2930Signer(chainID)))
+	assert.Nil(t, NewRawTransaction(dynamicFeeTx, "test").From())
+	assert.NoError(t, SetSigner(chainID, nil))
+	assert.Equal(t, &sender, NewRawTransaction(dynamicFeeTx, "test").From())
+
+	assert.Error(t, SetSigner(nil, types.NewEIP2930Signer(chainID)))
+	assert.Error(t, SetSigner(big.NewInt(-1), nil))
+}
+
+func TestRawTransactionFromCached(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	tx := NewRawTransaction(newSignedTx(key, big.NewInt(constant.BSC_MAINNET_CHAIN_ID), 0, nil), "test")
+
+	from := tx.From()
+	assert.Equal(t, &sender, from)
+	// the cache is not exposed
+	*from = common.Address{}
+	assert.Equal(t, &sender, tx.From())
+}
+
+// Senders of distinct transactions, so that nothing is cached.
+func newBenchmarkTxs(b *testing.B) []*types.Transaction {
+	key, _ := crypto.GenerateKey()
+	chainID := big.NewInt(constant.BSC_MAINNET_CHAIN_ID)
+	txs := make([]*types.Transaction, b.N)
+	for i := range txs {
+		txs[i] = newSignedTx(key, chainID, uint64(i), nil)
+	}
+	b.ResetTimer()
+	return txs
+}
+
+// How From() used to recover senders.
+func BenchmarkFromAsMessage(b *testing.B) {
+	txs := newBenchmarkTxs(b)
+	for _, tx := range txs {
+		if _, err := tx.AsMessage(types.LatestSignerForChainID(tx.ChainId()), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFrom(b *testing.B) {
+	txs := newBenchmarkTxs(b)
+	for _, tx := range txs {
+		if NewRawTransaction(tx, "bench").From() == nil {
+			b.Fatal("failed to recover the sender")
+		}
+	}
+}
+
+func BenchmarkFromCached(b *testing.B) {
+	txs := newBenchmarkTxs(b)
+	tx := NewRawTransaction(txs[0], "bench")
+	for i := 0; i < b.N; i++ {
+		if tx.From() == nil {
+			b.Fatal("failed to recover the sender")
+		}
+	}
+}
+
+func BenchmarkFromParallel(b *testing.B) {
+	txs := newBenchmarkTxs(b)
+	txCh := make(chan *types.Transaction, len(txs))
+	for _, tx := range txs {
+		txCh <- tx
+	}
+	close(txCh)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if NewRawTransaction(<-txCh, "bench").From() == nil {
+				b.Fatal("failed to recover the sender")
+			}
+		}
+	})
+}
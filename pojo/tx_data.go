@@ -1,31 +1,56 @@
 package pojo
 
 import (
+	"errors"
 	"math/big"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
-// Minimal transaction interface.
+// Minimal transaction interface, methods have the same semantics as
+// types.Transaction.
 //
-// types.Transaction and pojo.BlocknativeTx have implemented this interface.
+// RawTransaction, BlocknativeMsg and BloXrouteTx have implemented this
+// interface.
 type TxData interface {
 	Data() []byte
 	Gas() uint64
+	// The gas price of legacy transactions, the fee cap of EIP-1559 ones.
 	GasPrice() *big.Int
 	Value() *big.Int
 	Nonce() uint64
-	To() *common.Address
+	To() *common.Address // nil for contract creations
 	From() *common.Address
 	Hash() common.Hash
 	Source() string
+
+	Type() uint8
+	GasTipCap() *big.Int // the gas price of legacy transactions
+	GasFeeCap() *big.Int // the gas price of legacy transactions
+	// Derived from the signature of legacy transactions, zero if they are
+	// not replay protected, nil if unknown.
+	ChainID() *big.Int
+	AccessList() types.AccessList
+	// The canonical encoding as sent by eth_sendRawTransaction, which is the
+	// RLP for legacy transactions, ErrNoSignature if it can't be rebuilt.
+	MarshalBinary() ([]byte, error)
 }
 
+// The transaction can't be rebuilt without the signature.
+var ErrNoSignature = errors.New("the signature is absent")
+
 // A *types.Transaction wrapper which implememts the TxData interface.
 type RawTransaction struct {
 	*types.Transaction
-	source string
+	source      string
+	notifiedAt  time.Time
+	lookupDelay time.Duration
+
+	senderOnce sync.Once
+	sender     *common.Address // nil if the signature is invalid
 }
 
 func NewRawTransaction(tx *types.Transaction, fullnodeUrl string) *RawTransaction {
@@ -35,15 +60,40 @@ func NewRawTransaction(tx *types.Transaction, fullnodeUrl string) *RawTransactio
 	}
 }
 
+// A transaction looked up by its hash, which was notified at notifiedAt, the
+// lookup took lookupDelay.
+func NewLookedUpTransaction(tx *types.Transaction, fullnodeUrl string, notifiedAt time.Time, lookupDelay time.Duration) *RawTransaction {
+	return &RawTransaction{
+		Transaction: tx,
+		source:      fullnodeUrl,
+		notifiedAt:  notifiedAt,
+		lookupDelay: lookupDelay,
+	}
+}
+
+// When the hash was notified and how long the lookup took, zero if the
+// transaction was notified in full.
+func (tx *RawTransaction) Lookup() (time.Time, time.Duration) {
+	return tx.notifiedAt, tx.lookupDelay
+}
+
+// The sender is recovered by the signer of the chain on the first call, see
+// SignerForChainID(), it is safe to call concurrently.
 func (tx *RawTransaction) From() *common.Address {
-	// rawTx := (*types.Transaction)(tx)
-	msg, err := tx.AsMessage(types.LatestSignerForChainID(tx.ChainId()), nil)
-	if err != nil {
+	tx.senderOnce.Do(func() {
+		if from, err := types.Sender(SignerForChainID(tx.ChainId()), tx.Transaction); err == nil {
+			tx.sender = &from
+		}
+	})
+	if tx.sender == nil {
 		return nil
-	} else {
-		from := msg.From()
-		return &from
 	}
+	from := *tx.sender
+	return &from
+}
+
+func (tx *RawTransaction) ChainID() *big.Int {
+	return tx.ChainId()
 }
 
 func (tx *RawTransaction) Source() string {
@@ -0,0 +1,139 @@
+package pojo
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	bloXrouteTypes "github.com/crypto-crawler/bloxroute-go/types"
+	"github.com/crypto-crawler/fullnode-benchmarks/constant"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+var testChainID = big.NewInt(constant.ETHEREUM_MAINNET_CHAIN_ID)
+
+// A legacy, an access list and a dynamic fee transaction, the last creates
+// a contract.
+func newTestTransactions(key *ecdsa.PrivateKey) []*types.Transaction {
+	to := common.HexToAddress("0x10ED43C718714eb63d5aA57B78B54704E256024E")
+	accessList := types.AccessList{{Address: to, StorageKeys: []common.Hash{common.HexToHash("0x01")}}}
+	signer := types.LatestSignerForChainID(testChainID)
+	return []*types.Transaction{
+		types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(5e9), Gas: 21000, To: &to, Value: big.NewInt(1e18)}),
+		types.MustSignNewTx(key, signer, &types.AccessListTx{ChainID: testChainID, Nonce: 2, GasPrice: big.NewInt(6e9), Gas: 50000, To: &to, Data: common.FromHex("0x38ed1739"), AccessList: accessList}),
+		types.MustSignNewTx(key, signer, &types.DynamicFeeTx{ChainID: testChainID, Nonce: 3, GasTipCap: big.NewInt(1e9), GasFeeCap: big.NewInt(7e9), Gas: 100000, Data: common.FromHex("0x6080")}),
+	}
+}
+
+// A Blocknative event of tx as it is received.
+func newTestBlocknativeMsg(t *testing.T, tx *types.Transaction, from common.Address) *BlocknativeMsg {
+	to := ""
+	if tx.To() != nil {
+		to = tx.To().Hex()
+	}
+	fees := fmt.Sprintf(`"gasPrice": "%s"`, tx.GasPrice())
+	if tx.Type() == types.DynamicFeeTxType {
+		fees = fmt.Sprintf(`"maxFeePerGas": "%s", "maxPriorityFeePerGas": "%s"`, tx.GasFeeCap(), tx.GasTipCap())
+	}
+	accessList, _ := json.Marshal(tx.AccessList())
+	v, r, s := tx.RawSignatureValues()
+	data := fmt.Sprintf(`{"status": "ok", "event": {"blockchain": {"system": "ethereum", "network": "main"}, "transaction": {
+		"status": "pending", "hash": "%s", "from": "%s", "to": "%s", "value": "%s", "gas": %d, %s, "nonce": %d,
+		"input": "%s", "type": %d, "accessList": %s, "v": "%s", "r": "%s", "s": "%s"}}}`,
+		tx.Hash(), from.Hex(), to, tx.Value(), tx.Gas(), fees, tx.Nonce(),
+		hexutil.Encode(tx.Data()), tx.Type(), accessList, hexutil.EncodeBig(v), hexutil.EncodeBig(r), hexutil.EncodeBig(s))
+	msg := &BlocknativeMsg{}
+	assert.NoError(t, json.Unmarshal([]byte(data), msg))
+	return msg
+}
+
+func assertTxData(t *testing.T, expected *types.Transaction, from common.Address, actual TxData) {
+	msg := fmt.Sprintf("%s of type %d", actual.Source(), expected.Type())
+	assert.Equal(t, expected.Hash(), actual.Hash(), msg)
+	assert.Equal(t, expected.Type(), actual.Type(), msg)
+	assert.Equal(t, expected.Nonce(), actual.Nonce(), msg)
+	assert.Equal(t, expected.Gas(), actual.Gas(), msg)
+	assert.Equal(t, expected.GasPrice(), actual.GasPrice(), msg)
+	assert.Equal(t, expected.GasTipCap(), actual.GasTipCap(), msg)
+	assert.Equal(t, expected.GasFeeCap(), actual.GasFeeCap(), msg)
+	assert.Equal(t, expected.Value(), actual.Value(), msg)
+	assert.Equal(t, expected.To(), actual.To(), msg)
+	assert.Equal(t, &from, actual.From(), msg)
+	assert.Equal(t, testChainID, actual.ChainID(), msg)
+	assert.Equal(t, hexutil.Encode(expected.Data()), hexutil.Encode(actual.Data()), msg)
+	assert.Equal(t, len(expected.AccessList()), len(actual.AccessList()), msg)
+	if len(expected.AccessList()) > 0 {
+		assert.Equal(t, expected.AccessList(), actual.AccessList(), msg)
+	}
+	raw, err := actual.MarshalBinary()
+	assert.NoError(t, err, msg)
+	expectedRaw, _ := expected.MarshalBinary()
+	assert.Equal(t, expectedRaw, raw, msg)
+}
+
+func TestTxDataConversion(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	for _, tx := range newTestTransactions(key) {
+		assertTxData(t, tx, from, NewRawTransaction(tx, "fullnode"))
+
+		// Blocknative -> bloXroute -> fullnode
+		blocknativeMsg := newTestBlocknativeMsg(t, tx, from)
+		assertTxData(t, tx, from, blocknativeMsg)
+		raw, err := blocknativeMsg.MarshalBinary()
+		assert.NoError(t, err)
+		bloXrouteTx, err := NewBloXrouteTx(&bloXrouteTypes.Transaction{TxHash: tx.Hash().Hex(), RawTx: hexutil.Encode(raw)})
+		assert.NoError(t, err)
+		assertTxData(t, tx, from, bloXrouteTx)
+		raw, _ = bloXrouteTx.MarshalBinary()
+		rebuilt := new(types.Transaction)
+		assert.NoError(t, rebuilt.UnmarshalBinary(raw))
+		assertTxData(t, tx, from, NewRawTransaction(rebuilt, "fullnode"))
+	}
+}
+
+func TestBlocknativeMsgWithoutSignature(t *testing.T) {
+	msg := &BlocknativeMsg{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"event": {"blockchain": {"network": "main"}, "transaction": {"hash": "0x01", "input": "", "value": "10", "gasPrice": "1"}}}`), msg))
+	assert.Empty(t, msg.Data())
+	assert.Nil(t, msg.To())
+	assert.Equal(t, big.NewInt(10), msg.Value())
+	assert.Equal(t, big.NewInt(1), msg.GasFeeCap())
+	assert.Equal(t, big.NewInt(1), msg.ChainID())
+	_, err := msg.MarshalBinary()
+	assert.ErrorIs(t, err, ErrNoSignature)
+
+	// invalid input is reported without discarding the transaction
+	msg = &BlocknativeMsg{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"event": {"transaction": {"hash": "0x01", "input": "0xzz", "value": "10"}}}`), msg))
+	assert.Nil(t, msg.Data())
+	_, err = msg.DecodeInput()
+	assert.Error(t, err)
+	assert.Equal(t, big.NewInt(10), msg.Value())
+}
+
+func TestBlocknativeMsgWithoutFees(t *testing.T) {
+	for _, typ := range []int{types.LegacyTxType, types.DynamicFeeTxType} {
+		msg := &BlocknativeMsg{}
+		data := fmt.Sprintf(`{"status": "ok", "event": {"transaction": {"hash": "0x01", "status": "pending", "value": "0", "type": %d}}}`, typ)
+		assert.NoError(t, json.Unmarshal([]byte(data), msg))
+		assert.Equal(t, big.NewInt(0), msg.GasPrice(), typ)
+		assert.Equal(t, big.NewInt(0), msg.GasFeeCap(), typ)
+		assert.Equal(t, big.NewInt(0), msg.GasTipCap(), typ)
+		_, err := msg.Transaction()
+		assert.ErrorIs(t, err, ErrNoSignature, typ)
+	}
+
+	// the fee cap of an EIP-1559 transaction without gasPrice
+	msg := &BlocknativeMsg{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"event": {"transaction": {"hash": "0x01", "type": 2, "maxFeePerGas": "7", "maxPriorityFeePerGas": "1"}}}`), msg))
+	assert.Equal(t, big.NewInt(7), msg.GasPrice())
+	assert.Equal(t, big.NewInt(7), msg.GasFeeCap())
+	assert.Equal(t, big.NewInt(1), msg.GasTipCap())
+}
@@ -0,0 +1,253 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+)
+
+// Arrival times of one key, in microseconds since the engine started.
+type arrivals struct {
+	first      int  // index of the source with the earliest arrival, -1 if only backfilled
+	tied       bool // whether another source arrived at the same time as first
+	at         map[int]int64
+	backfilled map[int]bool // sources which fetched the key later, without a latency
+}
+
+func (a *arrivals) has(i int) bool {
+	_, ok := a.at[i]
+	return ok || a.backfilled[i]
+}
+
+// Engine computes latency statistics of multiple sources online.
+//
+// Each source reports the first time it sees a key (a block hash, a
+// transaction hash or a pair reserve hash), and the engine maintains the
+// gaps between every pair of sources, how often each source is the first
+// to see a key, and how many keys each source sees.
+//
+// Memory is bounded, only the latest `capacity` keys are kept to match
+// arrivals across sources, a key that arrives after being evicted is
+// counted as a new key.
+type Engine struct {
+	mu       sync.Mutex
+	start    time.Time
+	capacity int
+
+	sources []string
+	index   map[string]int // source -> index in sources
+
+	keys  map[string]*arrivals
+	order []string // ring buffer of keys in arrival order
+	head  int
+
+	total     uint64                // unique keys
+	seen      []uint64              // keys seen by each source, including backfilled ones
+	filled    []uint64              // keys backfilled by each source
+	wins      []uint64              // keys each source saw first, among keys seen by at least two sources
+	contested uint64                // keys seen by at least two sources
+	gaps      map[[2]int]*Histogram // gap = at[i] - at[j] with i < j
+}
+
+func NewEngine(capacity int) (*Engine, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("invalid capacity %d", capacity)
+	}
+	return &Engine{
+		start:    time.Now(),
+		capacity: capacity,
+		index:    make(map[string]int),
+		keys:     make(map[string]*arrivals),
+		order:    make([]string, capacity),
+		gaps:     make(map[[2]int]*Histogram),
+	}, nil
+}
+
+// Index of a source, registers the source if it is new.
+func (e *Engine) sourceIndex(source string) int {
+	if i, ok := e.index[source]; ok {
+		return i
+	}
+	i := len(e.sources)
+	e.sources = append(e.sources, source)
+	e.index[source] = i
+	e.seen = append(e.seen, 0)
+	e.filled = append(e.filled, 0)
+	e.wins = append(e.wins, 0)
+	return i
+}
+
+// AddSource registers a source, so that it is reported even before its first event.
+func (e *Engine) AddSource(source string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sourceIndex(source)
+}
+
+// Observe records that `source` received `key` at time `at`.
+//
+// Only the first arrival of a key at each source counts, later ones are
+// ignored. The win goes to the source with the smallest `at`, whichever
+// order the arrivals are observed in, or to no source on a tie.
+func (e *Engine) Observe(source string, key string, at time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	i := e.sourceIndex(source)
+	micros := at.Sub(e.start).Microseconds()
+
+	a := e.arrivals(key)
+	if a.has(i) {
+		return // duplicated
+	}
+
+	// events might be observed out of order, e.g., looked up transactions
+	// are backdated to their notification, so the earliest arrival wins,
+	// and nobody wins a tie, the same as utils.WinRates()
+	if len(a.at) > 1 && !a.tied {
+		e.wins[a.first]-- // recounted below
+	}
+	switch {
+	case len(a.at) == 0 || micros < a.at[a.first]:
+		a.first = i
+		a.tied = false
+	case micros == a.at[a.first]:
+		a.tied = true
+	}
+	if len(a.at) == 1 {
+		e.contested++
+	}
+	if len(a.at) > 0 && !a.tied {
+		e.wins[a.first]++
+	}
+	for j, other := range a.at {
+		if i < j {
+			e.histogram(i, j).Record(micros - other)
+		} else {
+			e.histogram(j, i).Record(other - micros)
+		}
+	}
+	a.at[i] = micros
+	e.seen[i]++
+}
+
+// Backfill records that `source` fetched `key` late, e.g., a block missed
+// during an outage, so it counts for coverage but neither for gaps nor wins.
+func (e *Engine) Backfill(source string, key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	i := e.sourceIndex(source)
+	a := e.arrivals(key)
+	if a.has(i) {
+		return // duplicated
+	}
+	a.backfilled[i] = true
+	e.seen[i]++
+	e.filled[i]++
+}
+
+// Arrivals of a key, registers the key if it is new.
+func (e *Engine) arrivals(key string) *arrivals {
+	a, ok := e.keys[key]
+	if !ok {
+		e.evict()
+		a = &arrivals{first: -1, at: make(map[int]int64), backfilled: make(map[int]bool)}
+		e.keys[key] = a
+		e.order[e.head] = key
+		e.head = (e.head + 1) % e.capacity
+		e.total++
+	}
+	return a
+}
+
+// Drop the oldest key if the ring buffer is full.
+func (e *Engine) evict() {
+	if oldest := e.order[e.head]; oldest != "" {
+		delete(e.keys, oldest)
+		e.order[e.head] = ""
+	}
+}
+
+func (e *Engine) histogram(i int, j int) *Histogram {
+	h, ok := e.gaps[[2]int{i, j}]
+	if !ok {
+		h = NewHistogram()
+		e.gaps[[2]int{i, j}] = h
+	}
+	return h
+}
+
+type SourceSnapshot struct {
+	Name       string  `json:"name"`
+	Seen       uint64  `json:"seen"`
+	Backfilled uint64  `json:"backfilled"`
+	Wins       uint64  `json:"wins"`
+	WinRate    float64 `json:"win_rate"` // wins divided by keys seen by at least two sources
+	Coverage   float64 `json:"coverage"` // keys seen by this source divided by all keys
+}
+
+// Gaps between two sources, in milliseconds, a negative gap means Source1 is faster.
+type PairSnapshot struct {
+	Source1 string           `json:"source1"`
+	Source2 string           `json:"source2"`
+	Gaps    utils.GapSummary `json:"gaps"`
+}
+
+type Snapshot struct {
+	Keys    uint64           `json:"keys"`
+	Sources []SourceSnapshot `json:"sources"`
+	Pairs   []PairSnapshot   `json:"pairs"`
+}
+
+// Snapshot of current statistics, gaps outside of the [lower, upper]
+// quantiles are trimmed as outliers.
+func (e *Engine) Snapshot(lower float64, upper float64) Snapshot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	snapshot := Snapshot{
+		Keys:    e.total,
+		Sources: make([]SourceSnapshot, len(e.sources)),
+		Pairs:   make([]PairSnapshot, 0, len(e.gaps)),
+	}
+	for i, name := range e.sources {
+		source := SourceSnapshot{Name: name, Seen: e.seen[i], Backfilled: e.filled[i], Wins: e.wins[i]}
+		if e.contested > 0 {
+			source.WinRate = float64(e.wins[i]) / float64(e.contested)
+		}
+		if e.total > 0 {
+			source.Coverage = float64(e.seen[i]) / float64(e.total)
+		}
+		snapshot.Sources[i] = source
+	}
+	for pair, h := range e.gaps {
+		snapshot.Pairs = append(snapshot.Pairs, PairSnapshot{
+			Source1: e.sources[pair[0]],
+			Source2: e.sources[pair[1]],
+			Gaps:    toMillis(h.Summary(lower, upper)),
+		})
+	}
+	sort.Slice(snapshot.Pairs, func(i, j int) bool {
+		a, b := snapshot.Pairs[i], snapshot.Pairs[j]
+		if a.Source1 != b.Source1 {
+			return e.index[a.Source1] < e.index[b.Source1]
+		}
+		return e.index[a.Source2] < e.index[b.Source2]
+	})
+	return snapshot
+}
+
+func toMillis(summary utils.GapSummary) utils.GapSummary {
+	summary.Mean /= 1000
+	summary.Std /= 1000
+	summary.Min /= 1000
+	summary.P50 /= 1000
+	summary.P90 /= 1000
+	summary.P99 /= 1000
+	summary.Max /= 1000
+	return summary
+}
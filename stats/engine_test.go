@@ -0,0 +1,122 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine(t *testing.T) {
+	e, err := NewEngine(2)
+	assert.NoError(t, err)
+	e.AddSource("c")
+	start := time.Now()
+	ms := func(n int) time.Time { return start.Add(time.Duration(n) * time.Millisecond) }
+
+	e.Observe("a", "x", ms(0))
+	e.Observe("b", "x", ms(10))
+	e.Observe("b", "x", ms(20)) // duplicated
+	e.Observe("b", "y", ms(30))
+	e.Observe("a", "y", ms(35))
+	e.Observe("a", "z", ms(40)) // evicts x
+	e.Observe("b", "x", ms(50)) // a new key, evicts y
+
+	snapshot := e.Snapshot(0, 1)
+	assert.Equal(t, uint64(4), snapshot.Keys)
+	assert.Equal(t, []SourceSnapshot{
+		{Name: "c", Seen: 0, Wins: 0, WinRate: 0, Coverage: 0},
+		{Name: "a", Seen: 3, Wins: 1, WinRate: 0.5, Coverage: 0.75},
+		{Name: "b", Seen: 3, Wins: 1, WinRate: 0.5, Coverage: 0.75},
+	}, snapshot.Sources)
+
+	assert.Equal(t, 1, len(snapshot.Pairs))
+	pair := snapshot.Pairs[0]
+	assert.Equal(t, "a", pair.Source1)
+	assert.Equal(t, "b", pair.Source2)
+	assert.Equal(t, 2, pair.Gaps.Count)
+	assert.InDelta(t, -10, pair.Gaps.Min, 0.001)
+	assert.InDelta(t, 5, pair.Gaps.Max, 0.001)
+	assert.InDelta(t, -2.5, pair.Gaps.Mean, 0.001)
+}
+
+func TestEngineOutOfOrder(t *testing.T) {
+	e, err := NewEngine(16)
+	assert.NoError(t, err)
+	start := time.Now()
+	ms := func(n int) time.Time { return start.Add(time.Duration(n) * time.Millisecond) }
+
+	// b is observed first, with a later timestamp
+	e.Observe("b", "x", ms(10))
+	e.Observe("a", "x", ms(0))
+	// the win moves from b to c
+	e.Observe("a", "y", ms(20))
+	e.Observe("b", "y", ms(10))
+	e.Observe("c", "y", ms(5))
+	e.Observe("c", "x", ms(30))
+
+	snapshot := e.Snapshot(0, 1)
+	wins := make(map[string]uint64)
+	for _, source := range snapshot.Sources {
+		wins[source.Name] = source.Wins
+	}
+	assert.Equal(t, map[string]uint64{"a": 1, "b": 0, "c": 1}, wins)
+}
+
+func TestEngineBackfill(t *testing.T) {
+	e, err := NewEngine(16)
+	assert.NoError(t, err)
+	start := time.Now()
+	ms := func(n int) time.Time { return start.Add(time.Duration(n) * time.Millisecond) }
+
+	e.Backfill("a", "x") // missed by a during an outage
+	e.Observe("b", "x", ms(0))
+	e.Observe("a", "x", ms(10)) // duplicated
+	e.Observe("a", "y", ms(20))
+	e.Observe("b", "y", ms(30))
+
+	snapshot := e.Snapshot(0, 1)
+	assert.Equal(t, uint64(2), snapshot.Keys)
+	assert.Equal(t, []SourceSnapshot{
+		{Name: "a", Seen: 2, Backfilled: 1, Wins: 1, WinRate: 1, Coverage: 1},
+		{Name: "b", Seen: 2, Backfilled: 0, Wins: 0, WinRate: 0, Coverage: 1},
+	}, snapshot.Sources)
+	// only y has a gap
+	assert.Equal(t, 1, snapshot.Pairs[0].Gaps.Count)
+	assert.InDelta(t, -10, snapshot.Pairs[0].Gaps.Mean, 0.001)
+}
+
+func TestEngineTie(t *testing.T) {
+	e, err := NewEngine(16)
+	assert.NoError(t, err)
+	start := time.Now()
+	ms := func(n int) time.Time { return start.Add(time.Duration(n) * time.Millisecond) }
+
+	// nobody wins a tie, like utils.WinRates()
+	e.Observe("a", "x", ms(0))
+	e.Observe("b", "x", ms(0))
+	// a tie for a win takes the win away
+	e.Observe("a", "y", ms(0))
+	e.Observe("b", "y", ms(10))
+	e.Observe("c", "y", ms(0))
+	// an earlier arrival breaks the tie
+	e.Observe("a", "z", ms(10))
+	e.Observe("b", "z", ms(10))
+	e.Observe("c", "z", ms(5))
+
+	snapshot := e.Snapshot(0, 1)
+	assert.Equal(t, uint64(3), snapshot.Keys)
+	wins := make(map[string]uint64)
+	for _, source := range snapshot.Sources {
+		wins[source.Name] = source.Wins
+	}
+	assert.Equal(t, map[string]uint64{"a": 0, "b": 0, "c": 1}, wins)
+	assert.InDelta(t, 1.0/3, snapshot.Sources[2].WinRate, 0.001)
+}
+
+func TestEngineInvalidCapacity(t *testing.T) {
+	_, err := NewEngine(0)
+	assert.Error(t, err)
+	_, err = NewEngine(-1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,172 @@
+package stats
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+)
+
+// Number of bits of precision kept for each value, values below
+// 2^subBucketBits are exact and larger values have a relative error
+// below 2^-(subBucketBits-1), i.e., 0.8%.
+const subBucketBits = 8
+
+// A log-linear histogram of signed integers in the spirit of HdrHistogram.
+//
+// Memory is bounded by the number of distinct buckets, which is a few
+// thousand at most, no matter how many values are recorded.
+type Histogram struct {
+	counts map[int]uint64 // bucket index -> count, negative indexes for negative values
+	total  uint64
+	min    int64
+	max    int64
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{
+		counts: make(map[int]uint64),
+		min:    math.MaxInt64,
+		max:    math.MinInt64,
+	}
+}
+
+// Map a magnitude to its bucket index.
+func bucketIndex(m uint64) int {
+	if m < 1<<subBucketBits {
+		return int(m)
+	}
+	shift := bits.Len64(m) - subBucketBits
+	sub := m >> shift // in [2^(subBucketBits-1), 2^subBucketBits)
+	return 1<<subBucketBits + (shift-1)<<(subBucketBits-1) + int(sub-1<<(subBucketBits-1))
+}
+
+// The middle of the magnitudes mapped to a bucket index.
+func bucketValue(index int) uint64 {
+	if index < 1<<subBucketBits {
+		return uint64(index)
+	}
+	i := index - 1<<subBucketBits
+	shift := i>>(subBucketBits-1) + 1
+	sub := uint64(i&(1<<(subBucketBits-1)-1)) + 1<<(subBucketBits-1)
+	low := sub << shift
+	return low + (1<<shift-1)/2
+}
+
+func (h *Histogram) Record(value int64) {
+	var index int
+	if value < 0 {
+		index = -bucketIndex(uint64(-value)) - 1 // -1 so that -0 is not confused with 0
+	} else {
+		index = bucketIndex(uint64(value))
+	}
+	h.counts[index]++
+	h.total++
+	if value < h.min {
+		h.min = value
+	}
+	if value > h.max {
+		h.max = value
+	}
+}
+
+func (h *Histogram) Count() uint64 {
+	return h.total
+}
+
+type bucket struct {
+	value float64
+	count uint64
+}
+
+// Buckets sorted by value, clamped to the exact min and max.
+func (h *Histogram) buckets() []bucket {
+	indexes := make([]int, 0, len(h.counts))
+	for index := range h.counts {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	result := make([]bucket, 0, len(indexes))
+	for _, index := range indexes {
+		var value float64
+		if index < 0 {
+			value = -float64(bucketValue(-index - 1))
+		} else {
+			value = float64(bucketValue(index))
+		}
+		value = math.Max(float64(h.min), math.Min(float64(h.max), value))
+		result = append(result, bucket{value: value, count: h.counts[index]})
+	}
+	return result
+}
+
+// Value at rank, which is zero-based.
+func valueAt(buckets []bucket, rank uint64) float64 {
+	seen := uint64(0)
+	for _, b := range buckets {
+		seen += b.count
+		if rank < seen {
+			return b.value
+		}
+	}
+	return buckets[len(buckets)-1].value
+}
+
+// Quantile computes the q-th quantile with linear interpolation between
+// ranks, the same as pandas but up to the precision of buckets.
+func quantile(buckets []bucket, total uint64, q float64) float64 {
+	pos := q * float64(total-1)
+	lower := valueAt(buckets, uint64(math.Floor(pos)))
+	upper := valueAt(buckets, uint64(math.Ceil(pos)))
+	return lower + (pos-math.Floor(pos))*(upper-lower)
+}
+
+func (h *Histogram) Quantile(q float64) float64 {
+	if h.total == 0 {
+		return math.NaN()
+	}
+	return quantile(h.buckets(), h.total, q)
+}
+
+// Summary of values within the [lower, upper] quantiles, the same
+// outlier trimming as utils.TrimOutliers().
+func (h *Histogram) Summary(lower float64, upper float64) utils.GapSummary {
+	if h.total == 0 {
+		return utils.GapSummary{}
+	}
+	all := h.buckets()
+	low := quantile(all, h.total, lower)
+	high := quantile(all, h.total, upper)
+
+	trimmed := make([]bucket, 0, len(all))
+	count := uint64(0)
+	sum := 0.0
+	for _, b := range all {
+		if b.value >= low && b.value <= high {
+			trimmed = append(trimmed, b)
+			count += b.count
+			sum += b.value * float64(b.count)
+		}
+	}
+	if count == 0 {
+		return utils.GapSummary{}
+	}
+
+	summary := utils.GapSummary{Count: int(count)}
+	summary.Mean = sum / float64(count)
+	if count > 1 {
+		variance := 0.0
+		for _, b := range trimmed {
+			variance += (b.value - summary.Mean) * (b.value - summary.Mean) * float64(b.count)
+		}
+		summary.Std = math.Sqrt(variance / float64(count-1))
+	}
+	summary.Min = trimmed[0].value
+	summary.P50 = quantile(trimmed, count, 0.5)
+	summary.P90 = quantile(trimmed, count, 0.9)
+	summary.P99 = quantile(trimmed, count, 0.99)
+	summary.Max = trimmed[len(trimmed)-1].value
+	return summary
+}
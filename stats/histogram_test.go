@@ -0,0 +1,47 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketIndex(t *testing.T) {
+	for _, m := range []uint64{0, 1, 255, 256, 257, 511, 512, 1000, 123456789, math.MaxInt64} {
+		value := bucketValue(bucketIndex(m))
+		assert.InEpsilon(t, float64(m)+1, float64(value)+1, 1.0/128, m)
+	}
+	// indexes are contiguous
+	for m := uint64(1); m < 1<<16; m++ {
+		assert.LessOrEqual(t, bucketIndex(m)-bucketIndex(m-1), 1)
+	}
+}
+
+func TestHistogramSummary(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	h := NewHistogram()
+	gaps := make([]int64, 0)
+	for i := 0; i < 100000; i++ {
+		gap := int64(r.NormFloat64()*50000) - 20000 // microseconds
+		gaps = append(gaps, gap)
+		h.Record(gap)
+	}
+	assert.Equal(t, uint64(100000), h.Count())
+
+	expected := utils.SummarizeGaps(utils.TrimOutliers(gaps, 0.05, 0.95))
+	actual := h.Summary(0.05, 0.95)
+	assert.InEpsilon(t, expected.Count, actual.Count, 0.01)
+	assert.InDelta(t, expected.Mean, actual.Mean, 500)
+	assert.InEpsilon(t, expected.Std, actual.Std, 0.01)
+	assert.InEpsilon(t, expected.P50, actual.P50, 0.01)
+	assert.InEpsilon(t, expected.P90, actual.P90, 0.01)
+	assert.InEpsilon(t, expected.Min, actual.Min, 0.01)
+	assert.InEpsilon(t, expected.Max, actual.Max, 0.01)
+
+	assert.InEpsilon(t, float64(h.min), h.Quantile(0), 0.01)
+	assert.InEpsilon(t, float64(h.max), h.Quantile(1), 0.01)
+	assert.True(t, math.IsNaN(NewHistogram().Quantile(0.5)))
+}
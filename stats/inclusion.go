@@ -0,0 +1,134 @@
+package stats
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A transaction included in a block, and how early each source saw it
+// pending.
+type Inclusion struct {
+	Key         string // the transaction hash, see Engine.Observe()
+	BlockHash   string
+	BlockNumber uint64
+	Position    int       // index in the block
+	IncludedAt  time.Time // when the block was received
+	FirstSeen   time.Time // zero if no source saw it pending
+	FirstSource string
+	// Milliseconds from each source seeing the transaction pending to the
+	// block, sources which didn't see it before the block are absent.
+	Deltas map[string]float64
+}
+
+// Whether any source saw the transaction pending before the block.
+func (i *Inclusion) Seen() bool {
+	return len(i.Deltas) > 0
+}
+
+// A block of the InclusionTracker.
+type IncludedBlock struct {
+	Hash       string
+	Number     uint64
+	Keys       []string // transaction hashes in order
+	IncludedAt time.Time
+}
+
+// Tracks when sources see pending transactions, and matches them against
+// transactions of new blocks.
+//
+// Memory is bounded like the Engine, only the latest `capacity` pending
+// transactions are kept, older ones are regarded as never seen.
+type InclusionTracker struct {
+	mu       sync.Mutex
+	capacity int
+
+	seen  map[string]map[string]time.Time // key -> source -> first seen
+	order []string                        // ring buffer of keys in arrival order
+	head  int
+
+	blocks     map[string]bool // blocks included recently
+	blockOrder []string
+	blockHead  int
+}
+
+// Blocks remembered to ignore duplicated notifications.
+const trackedBlocks = 1024
+
+func NewInclusionTracker(capacity int) (*InclusionTracker, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("invalid capacity %d", capacity)
+	}
+	return &InclusionTracker{
+		capacity:   capacity,
+		seen:       make(map[string]map[string]time.Time),
+		order:      make([]string, capacity),
+		blocks:     make(map[string]bool),
+		blockOrder: make([]string, trackedBlocks),
+	}, nil
+}
+
+// Observe records that `source` saw the pending transaction `key` at time
+// `at`, only the first time counts.
+func (t *InclusionTracker) Observe(source string, key string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sources, ok := t.seen[key]
+	if !ok {
+		if oldest := t.order[t.head]; oldest != "" {
+			delete(t.seen, oldest)
+		}
+		sources = make(map[string]time.Time)
+		t.seen[key] = sources
+		t.order[t.head] = key
+		t.head = (t.head + 1) % t.capacity
+	}
+	if _, ok := sources[source]; !ok {
+		sources[source] = at
+	}
+}
+
+// Include matches transactions of a block, returns an Inclusion per
+// transaction in order, or nil if the block has been included.
+//
+// A transaction included again in another block after a reorg is matched
+// again.
+func (t *InclusionTracker) Include(block IncludedBlock) []Inclusion {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.blocks[block.Hash] {
+		return nil
+	}
+	if oldest := t.blockOrder[t.blockHead]; oldest != "" {
+		delete(t.blocks, oldest)
+	}
+	t.blocks[block.Hash] = true
+	t.blockOrder[t.blockHead] = block.Hash
+	t.blockHead = (t.blockHead + 1) % trackedBlocks
+
+	inclusions := make([]Inclusion, len(block.Keys))
+	for i, key := range block.Keys {
+		inclusion := Inclusion{
+			Key:         key,
+			BlockHash:   block.Hash,
+			BlockNumber: block.Number,
+			Position:    i,
+			IncludedAt:  block.IncludedAt,
+			Deltas:      make(map[string]float64),
+		}
+		for source, at := range t.seen[key] {
+			if at.After(block.IncludedAt) {
+				continue // too late to count
+			}
+			inclusion.Deltas[source] = float64(block.IncludedAt.Sub(at).Microseconds()) / 1000
+			if inclusion.FirstSeen.IsZero() || at.Before(inclusion.FirstSeen) {
+				inclusion.FirstSeen = at
+				inclusion.FirstSource = source
+			}
+		}
+		inclusions[i] = inclusion
+	}
+	return inclusions
+}
@@ -0,0 +1,43 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInclusionTracker(t *testing.T) {
+	tracker, err := NewInclusionTracker(2)
+	assert.NoError(t, err)
+	start := time.Now()
+	ms := func(n int) time.Time { return start.Add(time.Duration(n) * time.Millisecond) }
+
+	tracker.Observe("a", "x", ms(0))
+	tracker.Observe("b", "x", ms(10))
+	tracker.Observe("a", "x", ms(20)) // duplicated
+	tracker.Observe("b", "y", ms(30))
+
+	block := IncludedBlock{Hash: "0x01", Number: 100, Keys: []string{"y", "z", "x"}, IncludedAt: ms(100)}
+	inclusions := tracker.Include(block)
+	assert.Equal(t, []Inclusion{
+		{Key: "y", BlockHash: "0x01", BlockNumber: 100, Position: 0, IncludedAt: ms(100), FirstSeen: ms(30), FirstSource: "b", Deltas: map[string]float64{"b": 70}},
+		{Key: "z", BlockHash: "0x01", BlockNumber: 100, Position: 1, IncludedAt: ms(100), Deltas: map[string]float64{}},
+		{Key: "x", BlockHash: "0x01", BlockNumber: 100, Position: 2, IncludedAt: ms(100), FirstSeen: ms(0), FirstSource: "a", Deltas: map[string]float64{"a": 100, "b": 90}},
+	}, inclusions)
+	assert.True(t, inclusions[0].Seen())
+	assert.False(t, inclusions[1].Seen())
+
+	assert.Nil(t, tracker.Include(block)) // duplicated
+
+	// evicts x
+	tracker.Observe("a", "w", ms(110))
+	inclusions = tracker.Include(IncludedBlock{Hash: "0x02", Number: 101, Keys: []string{"x", "w"}, IncludedAt: ms(120)})
+	assert.False(t, inclusions[0].Seen())
+	assert.Equal(t, map[string]float64{"a": 10}, inclusions[1].Deltas)
+}
+
+func TestInclusionTrackerInvalidCapacity(t *testing.T) {
+	_, err := NewInclusionTracker(0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+)
+
+// Coverage of the transactions of a block by pending transaction sources.
+//
+// A transaction which no source saw pending before the block is private, it
+// was likely sent to the validator directly.
+type BlockCoverage struct {
+	Hash    string         `json:"block_hash"`
+	Number  uint64         `json:"block_number"`
+	Txs     int            `json:"txs"`
+	Private []string       `json:"private"` // hashes of private transactions in order
+	Seen    map[string]int `json:"seen"`    // transactions seen pending by each source
+}
+
+// Private transactions divided by all transactions, 0 for an empty block.
+func (c *BlockCoverage) PrivateRate() float64 {
+	if c.Txs == 0 {
+		return 0
+	}
+	return float64(len(c.Private)) / float64(c.Txs)
+}
+
+// Summarize inclusions of a block returned by InclusionTracker.Include(),
+// every one of sources is reported even if it saw nothing.
+func NewBlockCoverage(block IncludedBlock, inclusions []Inclusion, sources []string) BlockCoverage {
+	coverage := BlockCoverage{
+		Hash:    block.Hash,
+		Number:  block.Number,
+		Txs:     len(inclusions),
+		Private: make([]string, 0),
+		Seen:    make(map[string]int, len(sources)),
+	}
+	for _, source := range sources {
+		coverage.Seen[source] = 0
+	}
+	for _, inclusion := range inclusions {
+		if !inclusion.Seen() {
+			coverage.Private = append(coverage.Private, inclusion.Key)
+		}
+		for source := range inclusion.Deltas {
+			coverage.Seen[source]++
+		}
+	}
+	return coverage
+}
+
+// Detect private transactions offline, by replaying timestamps of pending
+// transactions read from output files of sources, see utils.ReadTimestamps(),
+// against inclusions read by utils.ReadInclusions().
+//
+// Blocks are returned in the order of block numbers.
+func DetectPrivate(inclusions []utils.InclusionRecord, sources []string, timestamps []map[string]int64) []BlockCoverage {
+	capacity := 1
+	for _, m := range timestamps {
+		capacity += len(m)
+	}
+	tracker, _ := NewInclusionTracker(capacity) // capacity is positive
+	for i, m := range timestamps {
+		for key, receivedAt := range m {
+			tracker.Observe(sources[i], key, time.UnixMilli(receivedAt))
+		}
+	}
+
+	blocks := make(map[string]*IncludedBlock)
+	for _, record := range inclusions {
+		block, ok := blocks[record.BlockHash]
+		if !ok {
+			block = &IncludedBlock{Hash: record.BlockHash, Number: record.BlockNumber, IncludedAt: time.UnixMilli(record.ReceivedAt)}
+			blocks[record.BlockHash] = block
+		}
+		for len(block.Keys) <= record.Position {
+			block.Keys = append(block.Keys, "")
+		}
+		block.Keys[record.Position] = record.Hash
+	}
+	sorted := make([]*IncludedBlock, 0, len(blocks))
+	for _, block := range blocks {
+		// positions of missing records
+		keys := block.Keys[:0]
+		for _, key := range block.Keys {
+			if key != "" {
+				keys = append(keys, key)
+			}
+		}
+		block.Keys = keys
+		sorted = append(sorted, block)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Number != sorted[j].Number {
+			return sorted[i].Number < sorted[j].Number
+		}
+		return sorted[i].Hash < sorted[j].Hash
+	})
+
+	coverages := make([]BlockCoverage, 0, len(sorted))
+	for _, block := range sorted {
+		coverages = append(coverages, NewBlockCoverage(*block, tracker.Include(*block), sources))
+	}
+	return coverages
+}
@@ -0,0 +1,30 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectPrivate(t *testing.T) {
+	inclusions := []utils.InclusionRecord{
+		{Hash: "0x03", BlockHash: "0x0b", BlockNumber: 101, Position: 0, ReceivedAt: 2000},
+		{Hash: "0x01", BlockHash: "0x0a", BlockNumber: 100, Position: 0, ReceivedAt: 1000},
+		{Hash: "0x02", BlockHash: "0x0a", BlockNumber: 100, Position: 1, ReceivedAt: 1000},
+		{Hash: "0x05", BlockHash: "0x0b", BlockNumber: 101, Position: 2, ReceivedAt: 2000}, // position 1 is missing
+	}
+	timestamps := []map[string]int64{
+		{"0x01": 900, "0x03": 2100}, // 0x03 is seen after its block
+		{"0x01": 950, "0x05": 1500, "0x04": 1600},
+		{},
+	}
+
+	coverages := DetectPrivate(inclusions, []string{"a", "b", "c"}, timestamps)
+	assert.Equal(t, []BlockCoverage{
+		{Hash: "0x0a", Number: 100, Txs: 2, Private: []string{"0x02"}, Seen: map[string]int{"a": 1, "b": 1, "c": 0}},
+		{Hash: "0x0b", Number: 101, Txs: 2, Private: []string{"0x03"}, Seen: map[string]int{"a": 0, "b": 1, "c": 0}},
+	}, coverages)
+	assert.Equal(t, 0.5, coverages[0].PrivateRate())
+	assert.Equal(t, 0.0, (&BlockCoverage{}).PrivateRate())
+}
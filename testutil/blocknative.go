@@ -0,0 +1,298 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gorilla/websocket"
+)
+
+// A fake Blocknative websocket API, see https://docs.blocknative.com/websocket
+//
+// Every connection is greeted with a connect response, then it must be
+// initialized by checkDappId with the API key before it can put configs or
+// unwatch addresses.
+// Invalid messages are answered with an error status like Blocknative does,
+// scripted events are sent by Send().
+type Blocknative struct {
+	server *httptest.Server
+	apiKey string
+
+	mu      sync.Mutex
+	conns   []*blocknativeConn
+	inits   int
+	configs []map[string]interface{}
+}
+
+type blocknativeConn struct {
+	*websocket.Conn
+	mu          sync.Mutex // guards writes
+	initialized bool       // guarded by Blocknative.mu
+}
+
+func (c *blocknativeConn) write(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.WriteMessage(messageType, data)
+}
+
+func (c *blocknativeConn) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.write(websocket.TextMessage, data)
+}
+
+// Start a fake Blocknative API, which is closed when the test finishes.
+func NewBlocknative(t testing.TB, apiKey string) *Blocknative {
+	b := &Blocknative{apiKey: apiKey}
+	upgrader := websocket.Upgrader{}
+	b.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		b.serve(&blocknativeConn{Conn: conn})
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func (b *Blocknative) serve(conn *blocknativeConn) {
+	defer conn.Close()
+	if err := conn.writeJSON(pojo.ConnectResponse{ConnectionID: "fake", ServerVersion: "0.0.0", Status: "ok", Version: 1}); err != nil {
+		return
+	}
+	b.mu.Lock()
+	b.conns = append(b.conns, conn)
+	b.mu.Unlock()
+	defer b.remove(conn)
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		reason, config := b.check(conn, data)
+		if reason != "" {
+			err = conn.writeJSON(pojo.ConnectResponse{Status: "error", Reason: reason})
+		} else {
+			err = conn.writeJSON(pojo.ConnectResponse{ConnectionID: "fake", Status: "ok", Version: 1})
+		}
+		if err != nil {
+			return
+		}
+		// recorded after the response, so that events sent after WaitConfigs()
+		// don't overtake it
+		b.mu.Lock()
+		if reason == "" && config == nil {
+			b.inits++
+		} else if reason == "" {
+			b.configs = append(b.configs, config)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// Validate a message from the client, returns the reason if it is invalid,
+// and the message if it is a config or an unwatch.
+func (b *Blocknative) check(conn *blocknativeConn, data []byte) (string, map[string]interface{}) {
+	var base pojo.BaseMessage
+	if err := json.Unmarshal(data, &base); err != nil {
+		return "invalid JSON: " + err.Error(), nil
+	}
+	if base.DappID != b.apiKey {
+		return "invalid dappId", nil
+	}
+	if base.Version == "" || base.System == "" || base.Network == "" {
+		return "missing version or blockchain", nil
+	}
+
+	switch {
+	case base.CategoryCode == "initialize" && base.EventCode == "checkDappId":
+		b.mu.Lock()
+		conn.initialized = true
+		b.mu.Unlock()
+		return "", nil
+	case !conn.initialized:
+		return "not initialized", nil
+	case base.CategoryCode == "configs" && base.EventCode == "put":
+		var msg map[string]interface{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return "invalid JSON: " + err.Error(), nil
+		}
+		config, ok := msg["config"].(map[string]interface{})
+		if !ok {
+			return "missing config", nil
+		}
+		if scope, ok := config["scope"].(string); !ok || scope == "" {
+			return "missing config.scope", nil
+		}
+		if filters, ok := config["filters"]; ok {
+			if _, ok := filters.([]interface{}); !ok {
+				return "config.filters must be an array", nil
+			}
+		}
+		return "", msg
+	case base.CategoryCode == "accountAddress" && base.EventCode == "unwatch":
+		var msg map[string]interface{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return "invalid JSON: " + err.Error(), nil
+		}
+		account, ok := msg["account"].(map[string]interface{})
+		if !ok {
+			return "missing account", nil
+		}
+		if address, ok := account["address"].(string); !ok || !common.IsHexAddress(address) {
+			return "invalid account.address", nil
+		}
+		return "", msg
+	default:
+		return fmt.Sprintf("unsupported message %s/%s", base.CategoryCode, base.EventCode), nil
+	}
+}
+
+func (b *Blocknative) remove(conn *blocknativeConn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, c := range b.conns {
+		if c == conn {
+			b.conns = append(b.conns[:i], b.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *Blocknative) initializedConns() []*blocknativeConn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	conns := make([]*blocknativeConn, 0, len(b.conns))
+	for _, conn := range b.conns {
+		if conn.initialized {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+// The websocket URL.
+func (b *Blocknative) URL() string {
+	return "ws://" + strings.TrimPrefix(b.server.URL, "http://")
+}
+
+// Number of connections initialized so far, including closed ones.
+func (b *Blocknative) Connections() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inits
+}
+
+// Configs put and addresses unwatched so far by all connections, in order,
+// see eventCode.
+func (b *Blocknative) Configs() []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]map[string]interface{}{}, b.configs...)
+}
+
+// Wait until n configs have been put or unwatched, fails the test after 5 seconds.
+func (b *Blocknative) WaitConfigs(t testing.TB, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(b.Configs()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d configs put, expected %d", len(b.Configs()), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Send a message to all initialized connections.
+func (b *Blocknative) Send(msg interface{}) {
+	for _, conn := range b.initializedConns() {
+		conn.writeJSON(msg)
+	}
+}
+
+// Send a text message as it is, e.g., malformed JSON.
+func (b *Blocknative) SendRaw(data []byte) {
+	for _, conn := range b.initializedConns() {
+		conn.write(websocket.TextMessage, data)
+	}
+}
+
+// Close all connections with a close frame, e.g., websocket.CloseGoingAway.
+func (b *Blocknative) CloseConns(code int) {
+	b.mu.Lock()
+	conns := b.conns
+	b.conns = nil
+	b.mu.Unlock()
+	for _, conn := range conns {
+		conn.write(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""))
+		conn.Close()
+	}
+}
+
+// Close all connections without a close frame, i.e., an abnormal closure.
+func (b *Blocknative) DropConns() {
+	b.mu.Lock()
+	conns := b.conns
+	b.conns = nil
+	b.mu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+func (b *Blocknative) Close() {
+	b.CloseConns(websocket.CloseGoingAway)
+	b.server.Close()
+}
+
+// A Blocknative event of a transaction, status is pending, confirmed, etc.
+func NewBlocknativeMsg(tx *types.Transaction, status string) *pojo.BlocknativeMsg {
+	msg := &pojo.BlocknativeMsg{
+		Version:   1,
+		TimeStamp: time.Now(),
+		Status:    "ok",
+	}
+	msg.Event.CategoryCode = "activeAddress"
+	msg.Event.EventCode = "txPool"
+	msg.Event.Blockchain = pojo.Blockchain{System: "ethereum", Network: "bsc-main"}
+
+	transaction := &msg.Event.Transaction
+	transaction.TimeStamp = msg.TimeStamp
+	transaction.Status = status
+	transaction.Hash = tx.Hash().Hex()
+	if from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err == nil {
+		transaction.From = from.Hex()
+	}
+	if tx.To() != nil {
+		transaction.To = tx.To().Hex()
+	}
+	transaction.Value = tx.Value().String()
+	transaction.Gas = int(tx.Gas())
+	transaction.Nonce = int(tx.Nonce())
+	transaction.Input = hexutil.Encode(tx.Data())
+	transaction.Type = int(tx.Type())
+	if tx.Type() == types.DynamicFeeTxType {
+		transaction.MaxFeePerGas = tx.GasFeeCap().String()
+		transaction.MaxPriorityFeePerGas = tx.GasTipCap().String()
+	} else {
+		transaction.GasPrice = tx.GasPrice().String()
+	}
+	transaction.AccessList = tx.AccessList()
+	v, r, s := tx.RawSignatureValues()
+	transaction.V, transaction.R, transaction.S = (*hexutil.Big)(v), (*hexutil.Big)(r), (*hexutil.Big)(s)
+	return msg
+}
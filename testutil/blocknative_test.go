@@ -0,0 +1,50 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlocknativeValidation(t *testing.T) {
+	server := NewBlocknative(t, "key")
+	conn, _, err := websocket.DefaultDialer.Dial(server.URL(), nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	request := func(msg map[string]interface{}) pojo.ConnectResponse {
+		msg["dappId"] = "key"
+		msg["version"] = "1"
+		msg["blockchain"] = map[string]string{"system": "ethereum", "network": "main"}
+		assert.NoError(t, conn.WriteJSON(msg))
+		var response pojo.ConnectResponse
+		assert.NoError(t, conn.ReadJSON(&response))
+		return response
+	}
+	config := map[string]interface{}{"scope": "global", "filters": []interface{}{}}
+
+	var response pojo.ConnectResponse
+	assert.NoError(t, conn.ReadJSON(&response))
+	assert.Equal(t, "ok", response.Status)
+
+	response = request(map[string]interface{}{"categoryCode": "configs", "eventCode": "put", "config": config})
+	assert.Equal(t, "not initialized", response.Reason)
+	response = request(map[string]interface{}{"categoryCode": "initialize", "eventCode": "checkDappId"})
+	assert.Equal(t, "ok", response.Status)
+	response = request(map[string]interface{}{"categoryCode": "configs", "eventCode": "put", "config": map[string]interface{}{}})
+	assert.Equal(t, "missing config.scope", response.Reason)
+	response = request(map[string]interface{}{"categoryCode": "configs", "eventCode": "put", "config": config})
+	assert.Equal(t, "ok", response.Status)
+	response = request(map[string]interface{}{"categoryCode": "configs", "eventCode": "remove", "config": map[string]interface{}{"scope": "global"}})
+	assert.Equal(t, "unsupported message configs/remove", response.Reason)
+	response = request(map[string]interface{}{"categoryCode": "accountAddress", "eventCode": "unwatch", "account": map[string]interface{}{"address": "global"}})
+	assert.Equal(t, "invalid account.address", response.Reason)
+	response = request(map[string]interface{}{"categoryCode": "accountAddress", "eventCode": "unwatch", "account": map[string]interface{}{"address": "0x000000000000000000000000000000000000000a"}})
+	assert.Equal(t, "ok", response.Status)
+
+	assert.Equal(t, 1, server.Connections())
+	assert.Equal(t, 2, len(server.Configs()))
+	assert.Equal(t, "unwatch", server.Configs()[1]["eventCode"])
+}
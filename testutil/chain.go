@@ -0,0 +1,134 @@
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/constant"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Chain ID of the fake node, BSC mainnet.
+var ChainID = big.NewInt(constant.BSC_MAINNET_CHAIN_ID)
+
+// Reserves of a pair, see pair.GetReserves().
+type Reserve struct {
+	Reserve0           *big.Int
+	Reserve1           *big.Int
+	BlockTimestampLast uint32
+}
+
+// A scripted block.
+type Block struct {
+	// Announced as pending transactions while the previous block is the head,
+	// then mined in this block.
+	Txs []*types.Transaction
+	// Pairs whose reserves changed in this block, other pairs keep the
+	// reserves of earlier blocks.
+	Reserves map[common.Address]Reserve
+}
+
+// A scripted chain timeline.
+//
+// Block Start is the head when the node starts and block Start+i is mined
+// i*Interval later, even when nobody is connected. Blocks[i] is the content of
+// block Start+i, blocks after the script are empty. If Interval is zero,
+// blocks are mined only by Node.Mine().
+type Timeline struct {
+	Start    uint64
+	Interval time.Duration
+	Blocks   []Block
+}
+
+// Sign a contract call with the chain ID of the fake node.
+func NewTx(key *ecdsa.PrivateKey, nonce uint64, to common.Address, data []byte) *types.Transaction {
+	return types.MustSignNewTx(key, types.LatestSignerForChainID(ChainID), &types.LegacyTx{
+		Nonce:    nonce,
+		To:       &to,
+		Gas:      200000,
+		GasPrice: big.NewInt(5000000000),
+		Value:    big.NewInt(0),
+		Data:     data,
+	})
+}
+
+type txLocation struct {
+	number uint64 // the block which includes the transaction
+	index  uint
+}
+
+// The state of a timeline, safe for concurrent use.
+type chain struct {
+	timeline  Timeline
+	startedAt time.Time
+	mined     uint64 // by Mine(), only if timeline.Interval is zero
+	txs       map[common.Hash]txLocation
+}
+
+func newChain(timeline Timeline) *chain {
+	c := &chain{
+		timeline:  timeline,
+		startedAt: time.Now(),
+		txs:       make(map[common.Hash]txLocation),
+	}
+	for i, block := range timeline.Blocks {
+		for j, tx := range block.Txs {
+			c.txs[tx.Hash()] = txLocation{number: timeline.Start + uint64(i), index: uint(j)}
+		}
+	}
+	return c
+}
+
+func (c *chain) head() uint64 {
+	if c.timeline.Interval == 0 {
+		return c.timeline.Start + atomic.LoadUint64(&c.mined)
+	}
+	return c.timeline.Start + uint64(time.Since(c.startedAt)/c.timeline.Interval)
+}
+
+func (c *chain) mine() {
+	atomic.AddUint64(&c.mined, 1)
+}
+
+func (c *chain) block(number uint64) Block {
+	if number < c.timeline.Start || number-c.timeline.Start >= uint64(len(c.timeline.Blocks)) {
+		return Block{}
+	}
+	return c.timeline.Blocks[number-c.timeline.Start]
+}
+
+// The header of a block, no matter if it is mined.
+func (c *chain) header(number uint64) *types.Header {
+	offset := time.Duration(number-c.timeline.Start) * c.timeline.Interval
+	return &types.Header{
+		Number:     new(big.Int).SetUint64(number),
+		Difficulty: big.NewInt(1),
+		Time:       uint64(c.startedAt.Add(offset).Unix()),
+		TxHash:     types.DeriveSha(types.Transactions(c.block(number).Txs), trie.NewStackTrie(nil)),
+		Extra:      []byte{},
+	}
+}
+
+// Reserves of a pair after a block, false if the pair has none.
+func (c *chain) reserve(pair common.Address, number uint64) (Reserve, bool) {
+	if len(c.timeline.Blocks) == 0 {
+		return Reserve{}, false
+	}
+	last := c.timeline.Start + uint64(len(c.timeline.Blocks)) - 1
+	if number > last {
+		number = last // blocks after the script change nothing
+	}
+	for n := number; n >= c.timeline.Start; n-- {
+		if reserve, ok := c.block(n).Reserves[pair]; ok {
+			return reserve, true
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return Reserve{}, false
+}
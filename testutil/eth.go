@@ -0,0 +1,322 @@
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/fxfactorial/defi-abigen/contracts/uniswap/pair"
+
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// Returned by requests which were told to fail, see Node.Fail().
+var ErrInjected = errors.New("injected failure")
+
+var errReverted = errors.New("execution reverted")
+
+var (
+	pairABI, _       = ethabi.JSON(strings.NewReader(pair.PairABI))
+	bulkReaderABI, _ = abi.BulkReaderMetaData.GetAbi()
+)
+
+// How often subscriptions check for new blocks.
+const pollInterval = 2 * time.Millisecond
+
+// Requests which should fail, by method.
+type faults struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (f *faults) add(method string, count int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counts[method] += count
+}
+
+func (f *faults) take(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.counts[method] > 0 {
+		f.counts[method]--
+		return ErrInjected
+	}
+	return nil
+}
+
+// The eth namespace of the fake node.
+type ethService struct {
+	chain      *chain
+	faults     *faults
+	hashesOnly *int32 // see Node.SetHashesOnly()
+}
+
+func (s *ethService) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(ChainID)
+}
+
+func (s *ethService) BlockNumber() (hexutil.Uint64, error) {
+	if err := s.faults.take("eth_blockNumber"); err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(s.chain.head()), nil
+}
+
+// A mined block, with transaction hashes, or transactions if full is true.
+func (s *ethService) block(number uint64, full bool) (map[string]interface{}, error) {
+	bytes, err := json.Marshal(s.chain.header(number))
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(bytes, &fields); err != nil {
+		return nil, err
+	}
+	txs := make([]interface{}, 0)
+	for _, tx := range s.chain.block(number).Txs {
+		if full {
+			txs = append(txs, tx)
+		} else {
+			txs = append(txs, tx.Hash())
+		}
+	}
+	fields["transactions"] = txs
+	return fields, nil
+}
+
+func (s *ethService) GetBlockByNumber(number string, full bool) (map[string]interface{}, error) {
+	if err := s.faults.take("eth_getBlockByNumber"); err != nil {
+		return nil, err
+	}
+	head := s.chain.head()
+	if number == "latest" || number == "pending" {
+		return s.block(head, full)
+	}
+	n, err := hexutil.DecodeUint64(number)
+	if err != nil {
+		return nil, err
+	}
+	if n > head {
+		return nil, nil
+	}
+	return s.block(n, full)
+}
+
+// Recent blocks are found faster.
+func (s *ethService) GetBlockByHash(hash common.Hash, full bool) (map[string]interface{}, error) {
+	if err := s.faults.take("eth_getBlockByHash"); err != nil {
+		return nil, err
+	}
+	for n := s.chain.head(); n >= s.chain.timeline.Start; n-- {
+		if s.chain.header(n).Hash() == hash {
+			return s.block(n, full)
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return nil, nil
+}
+
+// Transactions of the next block are pending, those of later blocks are unknown.
+func (s *ethService) GetTransactionByHash(hash common.Hash) (map[string]interface{}, error) {
+	if err := s.faults.take("eth_getTransactionByHash"); err != nil {
+		return nil, err
+	}
+	location, ok := s.chain.txs[hash]
+	head := s.chain.head()
+	if !ok || location.number > head+1 {
+		return nil, nil
+	}
+
+	tx := s.chain.block(location.number).Txs[location.index]
+	bytes, err := json.Marshal(tx)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(bytes, &fields); err != nil {
+		return nil, err
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, err
+	}
+	fields["from"] = from
+	fields["blockHash"] = nil
+	fields["blockNumber"] = nil
+	fields["transactionIndex"] = nil
+	if location.number <= head {
+		fields["blockHash"] = s.chain.header(location.number).Hash()
+		fields["blockNumber"] = hexutil.Uint64(location.number)
+		fields["transactionIndex"] = hexutil.Uint64(location.index)
+	}
+	return fields, nil
+}
+
+// Every transaction succeeds and uses 21000 gas, receipts of pending
+// transactions are null.
+func (s *ethService) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	if err := s.faults.take("eth_getTransactionReceipt"); err != nil {
+		return nil, err
+	}
+	location, ok := s.chain.txs[hash]
+	if !ok || location.number > s.chain.head() {
+		return nil, nil
+	}
+	receipt := &types.Receipt{
+		Type:              s.chain.block(location.number).Txs[location.index].Type(),
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: 21000 * uint64(location.index+1),
+		Logs:              []*types.Log{},
+		TxHash:            hash,
+		GasUsed:           21000,
+		BlockHash:         s.chain.header(location.number).Hash(),
+		BlockNumber:       new(big.Int).SetUint64(location.number),
+		TransactionIndex:  location.index,
+	}
+	return receipt, nil
+}
+
+type callArgs struct {
+	To    *common.Address `json:"to"`
+	Data  hexutil.Bytes   `json:"data"`
+	Input hexutil.Bytes   `json:"input"`
+}
+
+// Supports pair.getReserves() and BulkReader.getReservesForBenchmark() of
+// any address, other calls are reverted.
+func (s *ethService) Call(args callArgs, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	if err := s.faults.take("eth_call"); err != nil {
+		return nil, err
+	}
+	number := s.chain.head()
+	if blockNrOrHash != nil {
+		if n, ok := blockNrOrHash.Number(); ok && n >= 0 && uint64(n) < number {
+			number = uint64(n)
+		}
+	}
+	data := args.Input
+	if len(data) == 0 {
+		data = args.Data
+	}
+	if len(data) < 4 || args.To == nil {
+		return nil, errReverted
+	}
+
+	if method, err := pairABI.MethodById(data[:4]); err == nil && method.Name == "getReserves" {
+		reserve, ok := s.chain.reserve(*args.To, number)
+		if !ok {
+			return nil, errReverted
+		}
+		return method.Outputs.Pack(reserve.Reserve0, reserve.Reserve1, reserve.BlockTimestampLast)
+	}
+	if method, err := bulkReaderABI.MethodById(data[:4]); err == nil && method.Name == "getReservesForBenchmark" {
+		inputs, err := method.Inputs.Unpack(data[4:])
+		if err != nil {
+			return nil, err
+		}
+		pairs := inputs[0].([]common.Address)
+		reserves := make([][3]*big.Int, len(pairs))
+		for i, pair := range pairs {
+			reserve, ok := s.chain.reserve(pair, number)
+			if !ok {
+				return nil, errReverted
+			}
+			reserves[i] = [3]*big.Int{
+				reserve.Reserve0,
+				reserve.Reserve1,
+				new(big.Int).SetUint64(uint64(reserve.BlockTimestampLast)),
+			}
+		}
+		return method.Outputs.Pack(reserves)
+	}
+	return nil, errReverted
+}
+
+// Notify the elements returned by next() with the range of new heads, until
+// the subscription ends. The elements returned by first(), optional, with the
+// current head are notified right away.
+func (s *ethService) subscribe(ctx context.Context, first func(head uint64) []interface{}, next func(from, to uint64) []interface{}) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	last := s.chain.head()
+	go func() {
+		if first != nil {
+			for _, x := range first(last) {
+				notifier.Notify(sub.ID, x)
+			}
+		}
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			case <-ticker.C:
+				head := s.chain.head()
+				if head <= last {
+					continue
+				}
+				for _, x := range next(last+1, head) {
+					notifier.Notify(sub.ID, x)
+				}
+				last = head
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (s *ethService) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	return s.subscribe(ctx, nil, func(from, to uint64) []interface{} {
+		headers := make([]interface{}, 0, to-from+1)
+		for n := from; n <= to; n++ {
+			headers = append(headers, s.chain.header(n))
+		}
+		return headers
+	})
+}
+
+// Transactions of a block are announced when the previous block is mined,
+// in full if fullTx is true.
+func (s *ethService) NewPendingTransactions(ctx context.Context, fullTx *bool) (*rpc.Subscription, error) {
+	full := fullTx != nil && *fullTx
+	if full && atomic.LoadInt32(s.hashesOnly) == 1 {
+		return nil, errors.New("too many arguments, want at most 1")
+	}
+	pending := func(from, to uint64) []interface{} {
+		txs := make([]interface{}, 0)
+		for n := from; n <= to; n++ {
+			for _, tx := range s.chain.block(n).Txs {
+				if full {
+					txs = append(txs, tx)
+				} else {
+					txs = append(txs, tx.Hash())
+				}
+			}
+		}
+		return txs
+	}
+	return s.subscribe(ctx, func(head uint64) []interface{} {
+		return pending(head+1, head+1)
+	}, func(from, to uint64) []interface{} {
+		return pending(from+1, to+1)
+	})
+}
@@ -0,0 +1,133 @@
+package testutil
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// A fake fullnode which serves JSON-RPC over websocket and HTTP on the same
+// address, its chain follows a scripted timeline.
+//
+// It can also pretend to be flaky, see Drop(), SetDown() and Fail().
+type Node struct {
+	server    *httptest.Server
+	rpcServer *rpc.Server
+	chain     *chain
+	faults    *faults
+
+	hashesOnly int32
+	down       int32
+	mu         sync.Mutex
+	conns      []net.Conn
+}
+
+// Start a fake node, which is closed when the test finishes.
+func NewNode(t testing.TB, timeline Timeline) *Node {
+	n := &Node{
+		rpcServer: rpc.NewServer(),
+		chain:     newChain(timeline),
+		faults:    &faults{counts: make(map[string]int)},
+	}
+	if err := n.rpcServer.RegisterName("eth", &ethService{chain: n.chain, faults: n.faults, hashesOnly: &n.hashesOnly}); err != nil {
+		t.Fatal(err)
+	}
+	wsHandler := n.rpcServer.WebsocketHandler([]string{"*"})
+
+	n.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&n.down) == 1 {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			wsHandler.ServeHTTP(w, r)
+		} else {
+			n.rpcServer.ServeHTTP(w, r)
+		}
+	}))
+	n.server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			n.mu.Lock()
+			n.conns = append(n.conns, conn)
+			n.mu.Unlock()
+		}
+	}
+	n.server.Start()
+	t.Cleanup(n.Close)
+	return n
+}
+
+// The websocket URL.
+func (n *Node) URL() string {
+	return "ws://" + strings.TrimPrefix(n.server.URL, "http://")
+}
+
+// The HTTP URL.
+func (n *Node) HTTPURL() string {
+	return n.server.URL
+}
+
+// The number of the latest block.
+func (n *Node) Head() uint64 {
+	return n.chain.head()
+}
+
+// Mine the next block, only if Timeline.Interval is zero.
+func (n *Node) Mine() {
+	n.chain.mine()
+}
+
+// The header of a block as the node returns it.
+func (n *Node) Header(number uint64) *types.Header {
+	return n.chain.header(number)
+}
+
+// Make the next count requests of a method fail with ErrInjected, e.g.,
+// Fail("eth_call", 3).
+func (n *Node) Fail(method string, count int) {
+	n.faults.add(method, count)
+}
+
+// Reject subscriptions of full pending transactions like nodes which only
+// notify hashes, subscriptions of hashes are not affected.
+func (n *Node) SetHashesOnly(hashesOnly bool) {
+	if hashesOnly {
+		atomic.StoreInt32(&n.hashesOnly, 1)
+	} else {
+		atomic.StoreInt32(&n.hashesOnly, 0)
+	}
+}
+
+// Close all connections, including websocket connections, subscriptions on
+// them end with an error.
+func (n *Node) Drop() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, conn := range n.conns {
+		conn.Close()
+	}
+	n.conns = nil
+}
+
+// Reject new connections with 503 while the node is down, existing
+// connections are not affected.
+func (n *Node) SetDown(down bool) {
+	if down {
+		atomic.StoreInt32(&n.down, 1)
+	} else {
+		atomic.StoreInt32(&n.down, 0)
+	}
+}
+
+func (n *Node) Close() {
+	n.Drop()
+	n.server.Close()
+	n.rpcServer.Stop()
+}
@@ -0,0 +1,142 @@
+package testutil
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/fxfactorial/defi-abigen/contracts/uniswap/pair"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	pair1 = common.HexToAddress("0x01")
+	pair2 = common.HexToAddress("0x02")
+)
+
+func TestNodeCall(t *testing.T) {
+	node := NewNode(t, Timeline{Start: 10, Blocks: []Block{
+		{Reserves: map[common.Address]Reserve{
+			pair1: {big.NewInt(1), big.NewInt(2), 3},
+			pair2: {big.NewInt(4), big.NewInt(5), 6},
+		}},
+		{Reserves: map[common.Address]Reserve{
+			pair1: {big.NewInt(7), big.NewInt(8), 9},
+		}},
+	}})
+	ethClient, err := ethclient.Dial(node.HTTPURL())
+	assert.NoError(t, err)
+	defer ethClient.Close()
+
+	pairInstance, err := pair.NewPair(pair1, ethClient)
+	assert.NoError(t, err)
+	reserves, err := pairInstance.GetReserves(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1), reserves.Reserve0)
+	assert.Equal(t, uint32(3), reserves.BlockTimestampLast)
+
+	node.Mine()
+	bulkReader, err := abi.NewBulkReader(common.HexToAddress("0x03"), ethClient)
+	assert.NoError(t, err)
+	arr, err := bulkReader.GetReservesForBenchmark(nil, []common.Address{pair1, pair2})
+	assert.NoError(t, err)
+	assert.Equal(t, [][3]*big.Int{
+		{big.NewInt(7), big.NewInt(8), big.NewInt(9)},
+		{big.NewInt(4), big.NewInt(5), big.NewInt(6)},
+	}, arr)
+
+	// an older block
+	arr, err = bulkReader.GetReservesForBenchmark(&bind.CallOpts{BlockNumber: big.NewInt(10)}, []common.Address{pair1})
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1), arr[0][0])
+
+	node.Fail("eth_call", 1)
+	_, err = bulkReader.GetReservesForBenchmark(nil, []common.Address{pair1})
+	assert.EqualError(t, err, ErrInjected.Error())
+	// unknown pairs revert
+	_, err = bulkReader.GetReservesForBenchmark(nil, []common.Address{common.HexToAddress("0x04")})
+	assert.Error(t, err)
+}
+
+func TestNodeTransactions(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	tx := NewTx(key, 0, pair1, []byte{1, 2, 3, 4})
+	node := NewNode(t, Timeline{Start: 10, Blocks: []Block{{}, {}, {Txs: []*types.Transaction{tx}}}})
+
+	rpcClient, err := rpc.Dial(node.URL())
+	assert.NoError(t, err)
+	defer rpcClient.Close()
+	ethClient := ethclient.NewClient(rpcClient)
+	ctx := context.Background()
+
+	headerCh := make(chan *types.Header, 8)
+	headerSub, err := ethClient.SubscribeNewHead(ctx, headerCh)
+	assert.NoError(t, err)
+	defer headerSub.Unsubscribe()
+	hashCh := make(chan common.Hash, 8)
+	hashSub, err := gethclient.New(rpcClient).SubscribePendingTransactions(ctx, hashCh)
+	assert.NoError(t, err)
+	defer hashSub.Unsubscribe()
+
+	// unknown until block 11 is mined
+	_, _, err = ethClient.TransactionByHash(ctx, tx.Hash())
+	assert.Equal(t, "not found", err.Error())
+
+	node.Mine()
+	assert.Equal(t, uint64(11), (<-headerCh).Number.Uint64())
+	assert.Equal(t, tx.Hash(), <-hashCh)
+	pending, isPending, err := ethClient.TransactionByHash(ctx, tx.Hash())
+	assert.NoError(t, err)
+	assert.True(t, isPending)
+	assert.Equal(t, tx.Hash(), pending.Hash())
+
+	node.Mine()
+	header := <-headerCh
+	assert.Equal(t, node.Header(12).Hash(), header.Hash())
+	assert.Equal(t, types.DeriveSha(types.Transactions{tx}, trie.NewStackTrie(nil)), header.TxHash)
+	mined, isPending, err := ethClient.TransactionByHash(ctx, tx.Hash())
+	assert.NoError(t, err)
+	assert.False(t, isPending)
+	// cached from the response
+	sender, err := ethClient.TransactionSender(ctx, mined, header.Hash(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), sender)
+
+	number, err := ethClient.BlockNumber(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12), number)
+	chainID, err := ethClient.ChainID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, ChainID, chainID)
+}
+
+func TestNodeTimeline(t *testing.T) {
+	node := NewNode(t, Timeline{Start: 100, Interval: 10 * time.Millisecond})
+	ethClient, err := ethclient.Dial(node.URL())
+	assert.NoError(t, err)
+	defer ethClient.Close()
+
+	time.Sleep(55 * time.Millisecond)
+	header, err := ethClient.HeaderByNumber(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, header.Number.Uint64(), uint64(105))
+	assert.Equal(t, node.Header(101).Hash(), func() common.Hash {
+		header, err := ethClient.HeaderByNumber(context.Background(), big.NewInt(101))
+		assert.NoError(t, err)
+		return header.Hash()
+	}())
+	// future blocks are not found
+	_, err = ethClient.HeaderByNumber(context.Background(), big.NewInt(1000))
+	assert.Error(t, err)
+}
@@ -0,0 +1,27 @@
+package utils
+
+import "time"
+
+// A clock shared by all subscriptions in one process.
+//
+// The wall clock is read only once at creation, afterwards time advances with
+// the monotonic clock, so timestamps taken in different goroutines are
+// comparable even if NTP adjusts the system time during a benchmark.
+type Clock struct {
+	start time.Time
+}
+
+func NewClock() *Clock {
+	return &Clock{start: time.Now()}
+}
+
+// Milliseconds since the Unix epoch.
+func (c *Clock) NowMilli() int64 {
+	return c.Milli(time.Now())
+}
+
+// Convert t, which must be taken by time.Now() in this process, to
+// milliseconds since the Unix epoch.
+func (c *Clock) Milli(t time.Time) int64 {
+	return c.start.UnixMilli() + t.Sub(c.start).Milliseconds()
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock(t *testing.T) {
+	clock := NewClock()
+	t1 := clock.NowMilli()
+	time.Sleep(10 * time.Millisecond)
+	t2 := clock.NowMilli()
+
+	assert.GreaterOrEqual(t, t2-t1, int64(10))
+	assert.InDelta(t, time.Now().UnixMilli(), t2, 5)
+}
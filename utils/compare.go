@@ -0,0 +1,412 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/ulikunitz/xz"
+)
+
+// Summary of the gaps between two sources, in milliseconds.
+//
+// A negative gap means the first source received the record earlier.
+type GapSummary struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+	Std   float64 `json:"std"`
+	Min   float64 `json:"min"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// SourceName strips directories and extensions from an output file, e.g.,
+// fullnode-tx for data/fullnode-tx.json.gz.
+func SourceName(file string) string {
+	name := filepath.Base(file)
+	for _, ext := range []string{".gz", ".xz", ".json"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name
+}
+
+// OpenOutputFile opens a file written by Run(), transparently decompressing
+// .json.gz and .json.xz files.
+func OpenOutputFile(file string) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(file, ".json"):
+		return f, nil
+	case strings.HasSuffix(file, ".json.gz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &wrappedReadCloser{Reader: gz, closers: []io.Closer{gz, f}}, nil
+	case strings.HasSuffix(file, ".json.xz"):
+		r, err := xz.NewReader(bufio.NewReader(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &wrappedReadCloser{Reader: r, closers: []io.Closer{f}}, nil
+	default:
+		f.Close()
+		return nil, fmt.Errorf("unsupported file extension: %s", file)
+	}
+}
+
+type wrappedReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (w *wrappedReadCloser) Close() error {
+	var err error
+	for _, c := range w.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// An outage of a source recorded in its output file, in milliseconds,
+// records received by other sources in this window are not comparable.
+type Outage struct {
+	Start int64  `json:"outage_start"`
+	End   int64  `json:"outage_end"`
+	Error string `json:"error,omitempty"`
+}
+
+// Call fn on every line of a file written by Run().
+func scanOutputFile(file string, fn func(line []byte)) error {
+	reader, err := OpenOutputFile(file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // raw transactions can be long
+	for scanner.Scan() {
+		fn(scanner.Bytes())
+	}
+	return scanner.Err()
+}
+
+// ReadTimestamps reads a file written by Run() and returns a map from the
+// identity of each record to its received_at timestamp.
+//
+// The identity is `PairReserve.Hash()` for pair reserves, and the block or
+// transaction hash otherwise. Malformed lines are ignored, so are backfilled
+// records, whose received_at is not a latency.
+func ReadTimestamps(file string) (map[string]int64, error) {
+	result := make(map[string]int64) // identity -> received_at
+	err := scanOutputFile(file, func(line []byte) {
+		key, receivedAt, backfilled, ok := parseTimestamp(line)
+		if ok && !backfilled {
+			result[key] = receivedAt
+		}
+	})
+	return result, err
+}
+
+// ReadBackfilled returns the identities of backfilled records in a file
+// written by Run(), see ReadTimestamps().
+func ReadBackfilled(file string) (map[string]bool, error) {
+	result := make(map[string]bool)
+	err := scanOutputFile(file, func(line []byte) {
+		key, _, backfilled, ok := parseTimestamp(line)
+		if ok && backfilled {
+			result[key] = true
+		}
+	})
+	return result, err
+}
+
+// ReadOutages reads the outage records of a file written by Run().
+func ReadOutages(file string) ([]Outage, error) {
+	result := make([]Outage, 0)
+	err := scanOutputFile(file, func(line []byte) {
+		if !bytes.Contains(line, []byte(`"outage_start"`)) {
+			return // fast path
+		}
+		outage := Outage{}
+		if err := json.Unmarshal(line, &outage); err == nil && outage.End >= outage.Start {
+			result = append(result, outage)
+		}
+	})
+	return result, err
+}
+
+// An inclusion record written by cmd/race, received_at is when the block was
+// received.
+type InclusionRecord struct {
+	Hash        string `json:"hash"`
+	BlockHash   string `json:"block_hash"`
+	BlockNumber uint64 `json:"block_number"`
+	Position    int    `json:"position"`
+	ReceivedAt  int64  `json:"received_at"`
+}
+
+// ReadInclusions reads the inclusion records of a file written by Run(),
+// hashes are in lower case.
+func ReadInclusions(file string) ([]InclusionRecord, error) {
+	result := make([]InclusionRecord, 0)
+	err := scanOutputFile(file, func(line []byte) {
+		record := InclusionRecord{}
+		if err := json.Unmarshal(line, &record); err == nil && record.Hash != "" && record.BlockHash != "" && record.Position >= 0 {
+			record.Hash = strings.ToLower(record.Hash)
+			record.BlockHash = strings.ToLower(record.BlockHash)
+			result = append(result, record)
+		}
+	})
+	return result, err
+}
+
+// ExcludeOutages removes every record received by any source during any of
+// the outages from all timestamps, returns the number of removed records.
+func ExcludeOutages(timestamps []map[string]int64, outages []Outage) int {
+	if len(outages) == 0 {
+		return 0
+	}
+	excluded := make(map[string]bool)
+	for _, m := range timestamps {
+		for key, receivedAt := range m {
+			for _, outage := range outages {
+				if receivedAt >= outage.Start && receivedAt <= outage.End {
+					excluded[key] = true
+					break
+				}
+			}
+		}
+	}
+	for key := range excluded {
+		for _, m := range timestamps {
+			delete(m, key)
+		}
+	}
+	return len(excluded)
+}
+
+// ExcludeInclusionOutages returns the inclusions which no outage could have
+// hidden from the sources, i.e., no outage overlaps the window from the
+// first time any source saw the transaction, or the block if none saw it
+// before, to the block.
+func ExcludeInclusionOutages(inclusions []InclusionRecord, timestamps []map[string]int64, outages []Outage) []InclusionRecord {
+	kept := make([]InclusionRecord, 0, len(inclusions))
+	for _, inclusion := range inclusions {
+		firstSeen := inclusion.ReceivedAt
+		for _, m := range timestamps {
+			if receivedAt, ok := m[inclusion.Hash]; ok && receivedAt < firstSeen {
+				firstSeen = receivedAt
+			}
+		}
+		overlapped := false
+		for _, outage := range outages {
+			if outage.Start <= inclusion.ReceivedAt && outage.End >= firstSeen {
+				overlapped = true
+				break
+			}
+		}
+		if !overlapped {
+			kept = append(kept, inclusion)
+		}
+	}
+	return kept
+}
+
+// Returns the identity, received_at and whether the record is backfilled.
+func parseTimestamp(line []byte) (string, int64, bool, bool) {
+	var obj struct {
+		ReceivedAt *int64 `json:"received_at"`
+		Hash       string `json:"hash"`
+		TxHash     string `json:"txHash"`
+		Pair       string `json:"pair"`
+		Backfilled bool   `json:"backfilled"`
+		// Blocknative transactions, see pojo.BlocknativeMsg
+		Event struct {
+			Transaction struct {
+				Hash string `json:"hash"`
+			} `json:"transaction"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal(line, &obj); err != nil || obj.ReceivedAt == nil {
+		return "", 0, false, false
+	}
+
+	switch {
+	case obj.Pair != "":
+		pairReserve := &pojo.PairReserve{}
+		if err := json.Unmarshal(line, pairReserve); err != nil || pairReserve.Reserve0 == nil || pairReserve.Reserve1 == nil {
+			return "", 0, false, false
+		}
+		return strconv.FormatUint(pairReserve.Hash(), 10), *obj.ReceivedAt, obj.Backfilled, true
+	case obj.Hash != "":
+		return strings.ToLower(obj.Hash), *obj.ReceivedAt, obj.Backfilled, true
+	case obj.TxHash != "":
+		return strings.ToLower(obj.TxHash), *obj.ReceivedAt, obj.Backfilled, true
+	case obj.Event.Transaction.Hash != "":
+		return strings.ToLower(obj.Event.Transaction.Hash), *obj.ReceivedAt, obj.Backfilled, true
+	default:
+		return "", 0, false, false
+	}
+}
+
+// CompareTimestamps returns `timestamps1[key] - timestamps2[key]` for every
+// key present in both maps.
+func CompareTimestamps(timestamps1 map[string]int64, timestamps2 map[string]int64) []int64 {
+	gaps := make([]int64, 0)
+	for key, receivedAt := range timestamps1 {
+		if other, ok := timestamps2[key]; ok {
+			gaps = append(gaps, receivedAt-other)
+		}
+	}
+	return gaps
+}
+
+// Quantile computes the q-th quantile of sorted values with linear
+// interpolation, the same as pandas.
+func Quantile(sorted []int64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	frac := pos - float64(lower)
+	return float64(sorted[lower]) + frac*float64(sorted[upper]-sorted[lower])
+}
+
+// TrimOutliers removes gaps outside of the [lower, upper] quantiles and
+// returns the remaining gaps sorted.
+func TrimOutliers(gaps []int64, lower float64, upper float64) []int64 {
+	sorted := make([]int64, len(gaps))
+	copy(sorted, gaps)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if len(sorted) == 0 {
+		return sorted
+	}
+
+	low := Quantile(sorted, lower)
+	high := Quantile(sorted, upper)
+	trimmed := make([]int64, 0, len(sorted))
+	for _, gap := range sorted {
+		if float64(gap) >= low && float64(gap) <= high {
+			trimmed = append(trimmed, gap)
+		}
+	}
+	return trimmed
+}
+
+// SummarizeGaps computes the distribution of sorted gaps.
+func SummarizeGaps(sorted []int64) GapSummary {
+	summary := GapSummary{Count: len(sorted)}
+	if len(sorted) == 0 {
+		return summary
+	}
+
+	sum := 0.0
+	for _, gap := range sorted {
+		sum += float64(gap)
+	}
+	summary.Mean = sum / float64(len(sorted))
+	if len(sorted) > 1 {
+		variance := 0.0
+		for _, gap := range sorted {
+			variance += (float64(gap) - summary.Mean) * (float64(gap) - summary.Mean)
+		}
+		summary.Std = math.Sqrt(variance / float64(len(sorted)-1)) // sample std, the same as pandas
+	}
+	summary.Min = float64(sorted[0])
+	summary.P50 = Quantile(sorted, 0.5)
+	summary.P90 = Quantile(sorted, 0.9)
+	summary.P99 = Quantile(sorted, 0.99)
+	summary.Max = float64(sorted[len(sorted)-1])
+	return summary
+}
+
+// HeadToHead returns the fractions of gaps in which the first source received
+// the record strictly earlier and strictly later.
+func HeadToHead(gaps []int64) (float64, float64) {
+	if len(gaps) == 0 {
+		return 0, 0
+	}
+	wins, losses := 0, 0
+	for _, gap := range gaps {
+		if gap < 0 {
+			wins++
+		} else if gap > 0 {
+			losses++
+		}
+	}
+	return float64(wins) / float64(len(gaps)), float64(losses) / float64(len(gaps))
+}
+
+// WinRates returns, for each source, the fraction of records it received
+// strictly first among the records received by at least two sources.
+func WinRates(timestamps []map[string]int64) []float64 {
+	wins := make([]int, len(timestamps))
+	total := 0
+
+	keys := make(map[string]bool)
+	for _, m := range timestamps {
+		for key := range m {
+			keys[key] = true
+		}
+	}
+	for key := range keys {
+		count := 0
+		winner := -1
+		earliest := int64(math.MaxInt64)
+		tie := false
+		for i, m := range timestamps {
+			receivedAt, ok := m[key]
+			if !ok {
+				continue
+			}
+			count++
+			if receivedAt < earliest {
+				earliest = receivedAt
+				winner = i
+				tie = false
+			} else if receivedAt == earliest {
+				tie = true
+			}
+		}
+		if count < 2 {
+			continue
+		}
+		total++
+		if !tie {
+			wins[winner]++
+		}
+	}
+
+	rates := make([]float64, len(timestamps))
+	if total == 0 {
+		return rates
+	}
+	for i, w := range wins {
+		rates[i] = float64(w) / float64(total)
+	}
+	return rates
+}
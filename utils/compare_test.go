@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTimestamps(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "block.json.gz")
+	f, err := os.Create(file)
+	assert.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(`{"hash":"0x81DF8D","received_at":1648743342572}
+{"txHash":"0xe81c5e","received_at":1648743343354}
+{"pair":"0x58f876857a02d6762e0101bb5c46a8c1ed44dc16","reserve0":"0xd9364e40e581d2dfdc52f","reserve1":"0x409dd0fd22cd782430f5","block_timestamp_last":1648442477,"block_number":16448132,"received_at":1648743358674}
+{"hash":"0x00b185","received_at":
+{"hash":"0x75e563"}
+`))
+	assert.NoError(t, gz.Close())
+	assert.NoError(t, f.Close())
+
+	timestamps, err := ReadTimestamps(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(timestamps))
+	assert.Equal(t, int64(1648743342572), timestamps["0x81df8d"])
+	assert.Equal(t, int64(1648743343354), timestamps["0xe81c5e"])
+
+	_, err = ReadTimestamps(filepath.Join(t.TempDir(), "block.csv"))
+	assert.Error(t, err)
+}
+
+func TestReadTimestampsBlocknative(t *testing.T) {
+	msg := &pojo.BlocknativeMsg{Status: "ok"}
+	msg.Event.Transaction.Hash = "0xE81C5E"
+	msg.Event.Transaction.Input = "0x38ed1739"
+	// written by clients.Records()
+	bytes, err := json.Marshal(msg)
+	assert.NoError(t, err)
+	record := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(bytes, &record))
+	record["received_at"] = 1648743343354
+	line, err := json.Marshal(record)
+	assert.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "blocknative.json")
+	assert.NoError(t, os.WriteFile(file, append(line, '\n'), 0644))
+	timestamps, err := ReadTimestamps(file)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"0xe81c5e": 1648743343354}, timestamps)
+}
+
+func TestOutages(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "block.json")
+	err := os.WriteFile(file, []byte(`{"hash":"0x01","received_at":1000}
+{"outage_start":1100,"outage_end":1500,"error":"EOF","received_at":1500}
+{"hash":"0x03","received_at":1600}
+{"hash":"0x02","backfilled":true,"received_at":1650}
+`), 0o644)
+	assert.NoError(t, err)
+
+	timestamps, err := ReadTimestamps(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(timestamps)) // neither outages nor backfilled records have latencies
+	backfilled, err := ReadBackfilled(file)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"0x02": true}, backfilled)
+	outages, err := ReadOutages(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []Outage{{Start: 1100, End: 1500, Error: "EOF"}}, outages)
+
+	other := map[string]int64{"0x01": 990, "0x02": 1200, "0x03": 1590}
+	all := []map[string]int64{timestamps, other}
+	assert.Equal(t, 1, ExcludeOutages(all, outages))
+	assert.Equal(t, map[string]int64{"0x01": 990, "0x03": 1590}, other)
+	assert.Equal(t, 2, len(timestamps))
+}
+
+func TestTrimOutliers(t *testing.T) {
+	gaps := make([]int64, 0)
+	for i := int64(100); i > 0; i-- {
+		gaps = append(gaps, i)
+	}
+	trimmed := TrimOutliers(gaps, 0.05, 0.95)
+	assert.Equal(t, 90, len(trimmed))
+	assert.Equal(t, int64(6), trimmed[0])
+	assert.Equal(t, int64(95), trimmed[len(trimmed)-1])
+
+	summary := SummarizeGaps(trimmed)
+	assert.Equal(t, 90, summary.Count)
+	assert.Equal(t, 50.5, summary.Mean)
+	assert.Equal(t, 50.5, summary.P50)
+	assert.InDelta(t, 26.1247, summary.Std, 0.0001)
+}
+
+func TestWinRates(t *testing.T) {
+	a := map[string]int64{"x": 1, "y": 5, "z": 3, "only-a": 0}
+	b := map[string]int64{"x": 2, "y": 4, "z": 3}
+	c := map[string]int64{"x": 3, "y": 6}
+
+	assert.Equal(t, []int64{-1}, CompareTimestamps(map[string]int64{"x": 1}, b))
+	win, loss := HeadToHead(CompareTimestamps(a, b))
+	assert.InDelta(t, 1.0/3, win, 1e-9)
+	assert.InDelta(t, 1.0/3, loss, 1e-9)
+
+	rates := WinRates([]map[string]int64{a, b, c})
+	// z is a tie, so nobody wins it
+	assert.InDeltaSlice(t, []float64{1.0 / 3, 1.0 / 3, 0}, rates, 1e-9)
+}
+
+func TestReadInclusions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "inclusion.json")
+	err := os.WriteFile(file, []byte(`{"hash":"0xAB","block_hash":"0x0A","block_number":100,"position":0,"received_at":1000,"deltas":{}}
+{"hash":"0x02","received_at":1000}
+{"hash":"0x03","block_hash":"0x0a","block_number":100,"position":1,"received_at":1000,"deltas":{"a":5}}
+`), 0o644)
+	assert.NoError(t, err)
+
+	inclusions, err := ReadInclusions(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []InclusionRecord{
+		{Hash: "0xab", BlockHash: "0x0a", BlockNumber: 100, Position: 0, ReceivedAt: 1000},
+		{Hash: "0x03", BlockHash: "0x0a", BlockNumber: 100, Position: 1, ReceivedAt: 1000},
+	}, inclusions)
+}
+
+func TestExcludeInclusionOutages(t *testing.T) {
+	inclusions := []InclusionRecord{
+		{Hash: "0x01", BlockHash: "0x0a", ReceivedAt: 2000}, // pending during the outage
+		{Hash: "0x02", BlockHash: "0x0a", ReceivedAt: 2000}, // first seen by the other source during the outage
+		{Hash: "0x03", BlockHash: "0x0a", ReceivedAt: 2000}, // first seen after the outage
+		{Hash: "0x04", BlockHash: "0x0b", ReceivedAt: 1300}, // not seen, the block is received during the outage
+		{Hash: "0x05", BlockHash: "0x0c", ReceivedAt: 1000}, // not seen, the block is received before the outage
+	}
+	timestamps := []map[string]int64{
+		{"0x01": 1050, "0x02": 1900, "0x03": 1600},
+		{"0x02": 1200, "0x03": 1700},
+	}
+	outages := []Outage{{Start: 1100, End: 1500}}
+	assert.Equal(t, []InclusionRecord{inclusions[2], inclusions[4]}, ExcludeInclusionOutages(inclusions, timestamps, outages))
+	assert.Equal(t, inclusions, ExcludeInclusionOutages(inclusions, timestamps, nil))
+
+	assert.Equal(t, "fullnode-tx", SourceName("data/fullnode-tx.json.gz"))
+	assert.Equal(t, "race", SourceName("race.json"))
+}
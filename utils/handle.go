@@ -0,0 +1,72 @@
+package utils
+
+import "context"
+
+// A handle of a task running in the background.
+type Handle struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// NewHandle runs fn in a goroutine, ctx passed to fn is cancelled by Close().
+func NewHandle(ctx context.Context, fn func(ctx context.Context) error) *Handle {
+	ctx, cancel := context.WithCancel(ctx)
+	h := &Handle{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(h.done)
+		h.err = fn(ctx)
+		cancel()
+	}()
+	return h
+}
+
+// Wait blocks until the task has finished and returns its error.
+func (h *Handle) Wait() error {
+	<-h.done
+	return h.err
+}
+
+// Close stops the task and blocks until it has finished.
+func (h *Handle) Close() error {
+	h.cancel()
+	return h.Wait()
+}
+
+// Done is closed after the task has finished.
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// A stream of values produced by a task running in the background.
+//
+// C is closed after the task has finished and all resources are released,
+// so ranging over C and then calling Wait() is the way to consume it.
+type Subscription[T any] struct {
+	*Handle
+	C <-chan T
+}
+
+// Go runs fn in a goroutine, fn sends values to outCh until ctx is
+// cancelled and must not close outCh.
+func Go[T any](ctx context.Context, size int, fn func(ctx context.Context, outCh chan<- T) error) *Subscription[T] {
+	outCh := make(chan T, size)
+	handle := NewHandle(ctx, func(ctx context.Context) error {
+		defer close(outCh)
+		return fn(ctx, outCh)
+	})
+	return &Subscription[T]{Handle: handle, C: outCh}
+}
+
+// Send x to outCh unless ctx is cancelled first, returns false if cancelled.
+func Send[T any](ctx context.Context, outCh chan<- T, x T) bool {
+	select {
+	case outCh <- x:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
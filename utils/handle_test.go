@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandle(t *testing.T) {
+	h := NewHandle(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.Equal(t, context.Canceled, h.Close())
+	<-h.Done()
+
+	err := errors.New("failed")
+	h = NewHandle(context.Background(), func(ctx context.Context) error {
+		return err
+	})
+	assert.Equal(t, err, h.Wait())
+}
+
+func TestGo(t *testing.T) {
+	released := false
+	sub := Go(context.Background(), 0, func(ctx context.Context, outCh chan<- int) error {
+		defer func() { released = true }()
+		for i := 0; ; i++ {
+			if !Send(ctx, outCh, i) {
+				return nil
+			}
+		}
+	})
+
+	assert.Equal(t, 0, <-sub.C)
+	assert.Equal(t, 1, <-sub.C)
+	assert.NoError(t, sub.Close())
+	assert.True(t, released)
+	for range sub.C {
+		// drained, C is closed after the task has finished
+	}
+}
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fxfactorial/defi-abigen/contracts/uniswap/pair"
+	"github.com/fxfactorial/defi-abigen/contracts/uniswap/router"
+)
+
+// A function known by a SelectorRegistry, method is nil if only the
+// signature is known.
+type selectorEntry struct {
+	name      string
+	signature string
+	method    *abi.Method
+}
+
+// SelectorRegistry maps function selectors to method names, and decodes
+// arguments of the methods whose ABI is known.
+//
+// It is not safe to add functions concurrently with lookups.
+type SelectorRegistry struct {
+	entries map[FunctionSelector]selectorEntry
+}
+
+func NewSelectorRegistry() *SelectorRegistry {
+	return &SelectorRegistry{entries: make(map[FunctionSelector]selectorEntry)}
+}
+
+// A registry of the Uniswap V2 router and pair, which PancakeSwap forked.
+func DefaultSelectorRegistry() *SelectorRegistry {
+	r := NewSelectorRegistry()
+	for _, abiJSON := range []string{router.RouterABI, pair.PairABI} {
+		if err := r.AddABI([]byte(abiJSON)); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// AddABI adds the methods of a contract ABI, or of a compiler artifact with
+// an "abi" field, methods with known selectors replace signatures.
+func (r *SelectorRegistry) AddABI(data []byte) error {
+	var artifact struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &artifact); err != nil {
+			return err
+		}
+		if len(artifact.ABI) == 0 {
+			return errors.New("no abi in the artifact")
+		}
+		data = artifact.ABI
+	}
+
+	parsed, err := abi.JSON(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for _, method := range parsed.Methods {
+		method := method
+		r.entries[BytesToFunctionSelector(method.ID)] = selectorEntry{
+			name:      method.RawName,
+			signature: method.Sig,
+			method:    &method,
+		}
+	}
+	return nil
+}
+
+// AddSignature adds a function by its signature, e.g.,
+// "swapExactTokensForTokens(uint256,uint256,address[],address,uint256)",
+// arguments of it can't be decoded. A function with the ABI is kept.
+func (r *SelectorRegistry) AddSignature(signature string) error {
+	signature = strings.ReplaceAll(strings.TrimSpace(signature), " ", "")
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return fmt.Errorf("invalid signature: %s", signature)
+	}
+	selector := SignatureToFunctionSelector(signature)
+	if entry, ok := r.entries[selector]; ok && entry.method != nil {
+		return nil
+	}
+	r.entries[selector] = selectorEntry{name: signature[:open], signature: signature}
+	return nil
+}
+
+// LoadFile adds an ABI if the file ends with .json, otherwise a list of
+// signatures, see LoadABIFile() and LoadSignatureFile().
+func (r *SelectorRegistry) LoadFile(file string) error {
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		return r.LoadABIFile(file)
+	}
+	return r.LoadSignatureFile(file)
+}
+
+// LoadABIFile adds the ABI in a file, see AddABI().
+func (r *SelectorRegistry) LoadABIFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := r.AddABI(data); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+// LoadSignatureFile adds a list of signatures, one per line, empty lines and
+// lines starting with # are skipped.
+func (r *SelectorRegistry) LoadSignatureFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := r.AddSignature(line); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// LoadSelectorRegistry adds comma-separated lists of ABI files and signature
+// files to DefaultSelectorRegistry(), e.g., the -abi and -signatures flags,
+// either can be empty.
+func LoadSelectorRegistry(abiFiles string, signatureFiles string) (*SelectorRegistry, error) {
+	r := DefaultSelectorRegistry()
+	for _, file := range strings.Split(abiFiles, ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			if err := r.LoadABIFile(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, file := range strings.Split(signatureFiles, ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			if err := r.LoadSignatureFile(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// Number of functions known.
+func (r *SelectorRegistry) Len() int {
+	return len(r.entries)
+}
+
+// A function call decoded from call data.
+type DecodedCall struct {
+	Selector  FunctionSelector
+	Method    string // empty if the selector is unknown
+	Signature string
+	// Decoded arguments by name, nil if the ABI is unknown. Integers are
+	// decimal strings and bytes are hex strings, so that they survive JSON.
+	Args map[string]interface{}
+}
+
+// Decode call data, returns false if it is shorter than a selector.
+//
+// An error is returned if the ABI is known but the arguments don't match,
+// the call still has the method name.
+func (r *SelectorRegistry) Decode(data []byte) (*DecodedCall, bool, error) {
+	if len(data) < FunctionSelectorLength {
+		return nil, false, nil
+	}
+	call := &DecodedCall{Selector: BytesToFunctionSelector(data)}
+	entry, ok := r.entries[call.Selector]
+	if !ok {
+		return call, true, nil
+	}
+	call.Method = entry.name
+	call.Signature = entry.signature
+	if entry.method == nil {
+		return call, true, nil
+	}
+
+	args := make(map[string]interface{})
+	if err := entry.method.Inputs.UnpackIntoMap(args, data[FunctionSelectorLength:]); err != nil {
+		return call, true, err
+	}
+	for name, value := range args {
+		args[name] = jsonValue(value)
+	}
+	call.Args = args
+	return call, true, nil
+}
+
+// Convert big integers and byte arrays, other values are JSON friendly.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		return v.String()
+	case []*big.Int:
+		values := make([]string, len(v))
+		for i, x := range v {
+			values[i] = x.String()
+		}
+		return values
+	case []byte:
+		return hexutil.Encode(v)
+	case [32]byte:
+		return hexutil.Encode(v[:])
+	default:
+		return value
+	}
+}
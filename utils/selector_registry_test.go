@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fxfactorial/defi-abigen/contracts/uniswap/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectorRegistryDecode(t *testing.T) {
+	registry := DefaultSelectorRegistry()
+	parsed, err := abi.JSON(strings.NewReader(router.RouterABI))
+	assert.NoError(t, err)
+	path := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+	to := common.HexToAddress("0x03")
+	data, err := parsed.Pack("swapExactTokensForTokens", big.NewInt(100), big.NewInt(90), path, to, big.NewInt(1650000000))
+	assert.NoError(t, err)
+
+	call, ok, err := registry.Decode(data)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x38ed1739", call.Selector.String())
+	assert.Equal(t, "swapExactTokensForTokens", call.Method)
+	assert.Equal(t, "swapExactTokensForTokens(uint256,uint256,address[],address,uint256)", call.Signature)
+	assert.Equal(t, "100", call.Args["amountIn"])
+	assert.Equal(t, "90", call.Args["amountOutMin"])
+	assert.Equal(t, path, call.Args["path"])
+	assert.Equal(t, to, call.Args["to"])
+
+	// truncated arguments
+	call, ok, err = registry.Decode(data[:40])
+	assert.True(t, ok)
+	assert.Error(t, err)
+	assert.Equal(t, "swapExactTokensForTokens", call.Method)
+	assert.Nil(t, call.Args)
+
+	// unknown selector
+	call, ok, err = registry.Decode(common.FromHex("0x12345678"))
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, "", call.Method)
+
+	// plain transfer
+	_, ok, _ = registry.Decode(nil)
+	assert.False(t, ok)
+}
+
+func TestSelectorRegistryLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	signatures := filepath.Join(dir, "signatures.txt")
+	assert.NoError(t, os.WriteFile(signatures, []byte("# ERC20\ntransfer(address, uint256)\n\napprove(address,uint256)\n"), 0644))
+	artifact := filepath.Join(dir, "Token.json")
+	assert.NoError(t, os.WriteFile(artifact, []byte(`{"contractName": "Token", "abi": [
+		{"type": "function", "name": "transfer", "inputs": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}], "outputs": []}
+	]}`), 0644))
+	invalid := filepath.Join(dir, "invalid.txt")
+	assert.NoError(t, os.WriteFile(invalid, []byte("transfer"), 0644))
+
+	registry := NewSelectorRegistry()
+	assert.NoError(t, registry.LoadFile(signatures))
+	assert.Equal(t, 2, registry.Len())
+	call, _, err := registry.Decode(common.FromHex("0xa9059cbb"))
+	assert.NoError(t, err)
+	assert.Equal(t, "transfer", call.Method)
+	assert.Nil(t, call.Args)
+
+	// the ABI replaces the signature, and is kept by signatures added later
+	assert.NoError(t, registry.LoadFile(artifact))
+	assert.NoError(t, registry.AddSignature("transfer(address,uint256)"))
+	assert.Equal(t, 2, registry.Len())
+	data := append(common.FromHex("0xa9059cbb"), common.LeftPadBytes([]byte{4}, 32)...)
+	data = append(data, common.LeftPadBytes([]byte{1, 0}, 32)...)
+	call, _, err = registry.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"to": common.HexToAddress("0x04"), "amount": "256"}, call.Args)
+
+	assert.Error(t, registry.LoadFile(invalid))
+	assert.Error(t, registry.LoadFile(filepath.Join(dir, "missing.json")))
+
+	// comma-separated lists of flags
+	registry, err = LoadSelectorRegistry(artifact, signatures+", ")
+	assert.NoError(t, err)
+	call, _, err = registry.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "transfer", call.Method)
+	assert.NotNil(t, call.Args)
+	registry, err = LoadSelectorRegistry("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultSelectorRegistry().Len(), registry.Len())
+	_, err = LoadSelectorRegistry(signatures, "")
+	assert.Error(t, err) // not an ABI
+}
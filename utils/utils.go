@@ -7,13 +7,13 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"log"
 	"math/big"
 	"os"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/crypto-crawler/fullnode-benchmarks/metrics"
 	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -21,19 +21,31 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
-func Run[T any](inputCh <-chan T, stopCh <-chan struct{}, outputFile string) {
+// Writes records to a file as JSON lines in the background.
+type Writer struct {
+	*Handle
+}
+
+// Run appends every record from inputCh to outputFile as a JSON line with a
+// received_at timestamp.
+//
+// The returned writer finishes after inputCh is closed and all lines are
+// flushed and fsynced, Close() stops reading inputCh early.
+func Run[T any](inputCh <-chan T, outputFile string) (*Writer, error) {
 	file, err := os.OpenFile(outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	defer file.Close()
 	bw := bufio.NewWriterSize(file, 32*1024) // 32KB buffer
-	defer bw.Flush()
 
 	mu := sync.Mutex{} // used between WriteString() and Flush()
 
 	outputCh := make(chan string, 65536)
+	queueDepth := metrics.WriterQueueDepth.WithLabelValues(outputFile)
+	flushDuration := metrics.FlushDuration.WithLabelValues(outputFile)
+	writerDone := make(chan struct{})
 	go func() {
+		defer close(writerDone)
 		for txt := range outputCh {
 			mu.Lock()
 			bw.WriteString(txt + "\n")
@@ -42,27 +54,65 @@ func Run[T any](inputCh <-chan T, stopCh <-chan struct{}, outputFile string) {
 	}()
 
 	ticker := time.NewTicker(time.Second) // flush per second
+	tickerDone := make(chan struct{})
 	go func() {
 		// Writing to disk is done in a separate goroutine to avoid blocking the main thread,
 		// so that the received_at field is precise.
-		for range ticker.C {
-			mu.Lock()
-			bw.Flush()
-			mu.Unlock()
+		for {
+			select {
+			case <-tickerDone:
+				return
+			case <-ticker.C:
+				queueDepth.Set(float64(len(outputCh)))
+				mu.Lock()
+				start := time.Now()
+				bw.Flush()
+				flushDuration.Observe(time.Since(start).Seconds())
+				mu.Unlock()
+			}
 		}
 	}()
 
-	for x := range inputCh {
-		bytes, _ := json.Marshal(x)
-		jsonMap := make(map[string]interface{})
-		json.Unmarshal(bytes, &jsonMap)
-		jsonMap["received_at"] = time.Now().UnixMilli()
-		bytes, _ = json.Marshal(jsonMap)
-		outputCh <- string(bytes)
-	}
+	handle := NewHandle(context.Background(), func(ctx context.Context) error {
+	LOOP:
+		for {
+			select {
+			case <-ctx.Done():
+				break LOOP
+			case x, ok := <-inputCh:
+				if !ok {
+					break LOOP
+				}
+				bytes, _ := json.Marshal(x)
+				jsonMap := make(map[string]interface{})
+				json.Unmarshal(bytes, &jsonMap)
+				// keep the timestamp if the record was stamped on arrival
+				if _, ok := jsonMap["received_at"]; !ok {
+					jsonMap["received_at"] = time.Now().UnixMilli()
+				}
+				bytes, _ = json.Marshal(jsonMap)
+				outputCh <- string(bytes)
+			}
+		}
 
-	ticker.Stop()
-	close(outputCh)
+		ticker.Stop()
+		close(tickerDone)
+		close(outputCh)
+		<-writerDone
+		queueDepth.Set(0)
+
+		// make sure nothing is lost when the process exits
+		if err := bw.Flush(); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	})
+	return &Writer{Handle: handle}, nil
 }
 
 // Decode the data of ethOnBlock of GetReserves()
@@ -107,8 +157,10 @@ func ReadPairs(pairFile string) ([]common.Address, error) {
 // Call ethClient.TransactionByHash() repeatedly until the transaction is returned.
 //
 // count, total number of requests, should be greater than zero.
-func TransactionByHashWithRetry(ethClient *ethclient.Client, txHash common.Hash, count int) (*types.Transaction, bool, error) {
-	ctx := context.Background()
+//
+// Deprecated: clients.FetchTransactions() looks up many hashes with one
+// batch request.
+func TransactionByHashWithRetry(ctx context.Context, ethClient *ethclient.Client, txHash common.Hash, count int) (*types.Transaction, bool, error) {
 	var tx *types.Transaction
 	var isPending bool
 	var err error
@@ -118,12 +170,20 @@ func TransactionByHashWithRetry(ethClient *ethclient.Client, txHash common.Hash,
 	for i := 0; i < count; i++ {
 		tx, isPending, err = ethClient.TransactionByHash(ctx, txHash)
 		if tx != nil || err != ethereum.NotFound {
+			if err != nil {
+				metrics.RPCErrors.WithLabelValues("eth_getTransactionByHash").Inc()
+			}
 			return tx, isPending, err
 		}
 
-		time.Sleep(interval)
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(interval):
+		}
 		interval *= 2
 	}
 
+	metrics.RPCErrors.WithLabelValues("eth_getTransactionByHash").Inc()
 	return tx, isPending, errors.New("timeout, " + strconv.FormatInt(time.Since(start).Milliseconds(), 10) + "ms elapsed")
 }
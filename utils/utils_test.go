@@ -2,6 +2,9 @@ package utils
 
 import (
 	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/crypto-crawler/fullnode-benchmarks/pojo"
@@ -24,3 +27,31 @@ func TestDecodeReturnedDataOfGetReserves(t *testing.T) {
 	assert.Equal(t, uint32(1648442477), pairReserve.BlockTimestampLast)
 	assert.Equal(t, int64(16448132), pairReserve.BlockNumber)
 }
+
+func TestRun(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "output.json")
+	inputCh := make(chan map[string]interface{}, 2)
+	writer, err := Run(inputCh, outputFile)
+	assert.NoError(t, err)
+
+	inputCh <- map[string]interface{}{"hash": "0x01", "received_at": 1}
+	inputCh <- map[string]interface{}{"hash": "0x02"}
+	close(inputCh)
+	// everything is flushed once the writer has finished
+	assert.NoError(t, writer.Wait())
+
+	bytes, err := os.ReadFile(outputFile)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(bytes)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, `{"hash":"0x01","received_at":1}`, lines[0])
+	assert.Contains(t, lines[1], `"hash":"0x02"`)
+	assert.Contains(t, lines[1], `"received_at":`)
+}
+
+func TestRunClose(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "output.json")
+	writer, err := Run(make(chan int), outputFile)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+}